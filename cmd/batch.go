@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// defaultJobs is the default number of concurrent workers for batch commands
+const defaultJobs = 4
+
+// jobResult is the outcome of processing one item of a batch.
+// Output is buffered per item so it can be printed in input order.
+type jobResult struct {
+	output  string
+	url     string
+	skipped bool
+	err     error
+	// records describe the stored objects for --output
+	records []outputRecord
+}
+
+// batchSummary tallies the outcome of a batch command
+type batchSummary struct {
+	// verb and noun describe the operation, e.g. "Uploaded" and "files"
+	verb     string
+	noun     string
+	total    int
+	done     int
+	skipped  int
+	failures []string
+	aborted  []string
+	// errs holds the errors of failed items to choose the exit code
+	errs []error
+}
+
+// add records the result of one item
+func (s *batchSummary) add(name string, err error) {
+	switch {
+	case err == nil:
+		s.done++
+	case errors.Is(err, context.Canceled):
+		s.aborted = append(s.aborted, name)
+	default:
+		s.failures = append(s.failures, fmt.Sprintf("%s: %s", name, err))
+		s.errs = append(s.errs, err)
+	}
+}
+
+// skip records an item that completed without needing any work
+func (s *batchSummary) skip() {
+	s.done++
+	s.skipped++
+}
+
+// print writes the final tally, listing failed, aborted and skipped items
+func (s *batchSummary) print() {
+	w := humanOutput()
+	notStarted := s.total - s.done - len(s.failures) - len(s.aborted)
+
+	fmt.Fprintf(w, "\n%s %d of %d %s, %d failed", s.verb, s.done-s.skipped, s.total, s.noun, len(s.failures))
+	if s.skipped > 0 {
+		fmt.Fprintf(w, ", %d skipped", s.skipped)
+	}
+	if len(s.aborted) > 0 || notStarted > 0 {
+		fmt.Fprintf(w, ", %d aborted, %d not started", len(s.aborted), notStarted)
+	}
+	fmt.Fprintln(w)
+
+	for _, failure := range s.failures {
+		fmt.Fprintf(w, "  FAILED  %s\n", failure)
+	}
+	for _, name := range s.aborted {
+		fmt.Fprintf(w, "  ABORTED %s\n", name)
+	}
+}
+
+// ok reports whether every item of the batch completed successfully
+func (s *batchSummary) ok() bool {
+	return s.done == s.total
+}
+
+// err returns nil if every item completed, and otherwise an error joining the failures,
+// which were already printed, so the command exits with a code matching them
+func (s *batchSummary) err() error {
+	if s.ok() {
+		return nil
+	}
+	if len(s.errs) == 0 {
+		// Only aborted or not started items, the run was interrupted
+		return &reportedError{err: context.Canceled}
+	}
+	return &reportedError{err: errors.Join(s.errs...)}
+}
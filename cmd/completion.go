@@ -43,7 +43,7 @@ PowerShell:
 `,
 	DisableFlagsInUseLine: true,
 	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
-	Args:                  cobra.ExactValidArgs(1),
+	Args:                  usageArgs(cobra.ExactValidArgs(1)),
 	Run: func(cmd *cobra.Command, args []string) {
 		switch args[0] {
 		case "bash":
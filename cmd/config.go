@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+var (
+	configInitPath   string
+	configInitForce  bool
+	configInitValues = make(map[string]*string)
+)
+
+// configInitFlags maps the flags of "config init" to the keys they set
+var configInitFlags = []struct {
+	flag string
+	key  string
+}{
+	{"backend", "backend"},
+	{"bucket", "s3.bucket"},
+	{"endpoint", "s3.endpoint"},
+	{"region", "s3.region"},
+	{"access-key", "s3.access_key"},
+	{"secret-key", "s3.secret_key"},
+	{"root", "local.root"},
+	{"base-url", "local.base_url"},
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and manage the configuration",
+	Long: `Inspect and manage the configuration in config.toml.
+
+Example:
+  imgood config init
+  imgood config show
+  imgood config set s3.region eu-central-1
+  imgood config validate
+  imgood config profiles`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// A broken profile must not prevent fixing the configuration
+		if err := config.UseProfile(viper.GetString("profile")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// If no subcommand is provided, show help
+		return cmd.Help()
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a config file",
+	Long: `Create a config file, by default ~/.config/imgood/config.toml.
+
+Values can be given with flags. When no values are given and the command runs
+in a terminal, they are asked for interactively.
+
+Example:
+  imgood config init
+  imgood config init --bucket my-images --region us-east-1 --access-key KEY --secret-key SECRET
+  imgood config init --backend local --root /var/www/images --path ./config.toml`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configInitPath
+		if path == "" {
+			defaultPath, err := config.DefaultFile()
+			if err != nil {
+				return err
+			}
+			path = defaultPath
+		}
+
+		// Never replace an existing file by accident
+		if _, err := os.Stat(path); err == nil && !configInitForce {
+			return errs.Wrap(errs.ErrConflict, fmt.Errorf("config file already exists: %s (use --force to replace it)", path))
+		}
+
+		values := make(map[string]string)
+		for _, f := range configInitFlags {
+			if cmd.Flags().Changed(f.flag) {
+				values[f.key] = *configInitValues[f.flag]
+			}
+		}
+
+		if len(values) == 0 && isTerminal(os.Stdin) {
+			promptConfigValues(os.Stdin, values)
+		}
+
+		backend := strings.ToLower(values["backend"])
+		if backend == "" {
+			backend = config.BackendS3
+		}
+		if backend != config.BackendS3 && backend != config.BackendLocal {
+			return usageErrorf("unsupported storage backend: %s (expected %s or %s)", backend, config.BackendS3, config.BackendLocal)
+		}
+		values["backend"] = backend
+
+		if err := config.WriteFile(path, values); err != nil {
+			return err
+		}
+		fmt.Printf("Created config file: %s\n", path)
+		fmt.Println("Run 'imgood config validate' to check the settings")
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration",
+	Long: `Show the effective configuration after merging the config file, the selected
+profile, environment variables and flags, with the source of each value.
+Secrets are masked.
+
+Example:
+  imgood config show
+  imgood --profile prod config show`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := config.File()
+		if file == "" {
+			file = "none"
+		}
+		profile := config.ActiveProfile()
+		if profile == "" {
+			profile = "none"
+		}
+		fmt.Printf("Config file: %s\n", file)
+		fmt.Printf("Profile: %s\n\n", profile)
+
+		fmt.Printf("%-20s %-40s %s\n", "KEY", "VALUE", "SOURCE")
+		fmt.Println(strings.Repeat("-", 80))
+		for _, setting := range config.Settings {
+			value := viper.GetString(setting.Key)
+			if setting.Secret {
+				value = config.Mask(value)
+			}
+
+			source := config.Source(setting.Key)
+			switch source {
+			case config.SourceEnv:
+				source += " (" + config.EnvVar(setting.Key) + ")"
+			case config.SourceProfile:
+				source += " (" + config.ActiveProfile() + ")"
+			}
+
+			fmt.Printf("%-20s %-40s %s\n", setting.Key, value, source)
+		}
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a value in the config file",
+	Long: `Set a value in the config file in use, or create ~/.config/imgood/config.toml
+when there is none. Use "profiles.<name>.<key>" to set a value of a profile.
+Comments in the file are not preserved.
+
+Example:
+  imgood config set s3.bucket my-images
+  imgood config set profiles.prod.s3.bucket images-prod
+  imgood config set default_profile prod`,
+	Args: usageArgs(cobra.ExactArgs(2)),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		keys := make([]string, 0, len(config.Settings))
+		for _, setting := range config.Settings {
+			keys = append(keys, setting.Key)
+		}
+		return keys, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := strings.ToLower(args[0]), args[1]
+
+		path := config.File()
+		if path == "" {
+			defaultPath, err := config.DefaultFile()
+			if err != nil {
+				return err
+			}
+			path = defaultPath
+		}
+
+		if err := config.SetValue(path, key, value); err != nil {
+			return err
+		}
+		fmt.Printf("Set %s in %s\n", key, path)
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check that the storage backend is reachable with the configured credentials",
+	Long: `Check the configuration by connecting to the storage backend. For S3 this sends
+a HeadBucket request, which proves that the endpoint is reachable, the bucket
+exists and the credentials can access it.
+
+Example:
+  imgood config validate
+  imgood --profile prod config validate`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if validator, ok := store.(storage.Validator); ok {
+			if err := validator.Validate(cmd.Context()); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Configuration OK: %s is accessible\n", storageLocation())
+		return nil
+	},
+}
+
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List the configured profiles",
+	Long: `List the profiles defined in [profiles.<name>] tables of config.toml.
+
+Profiles override the top-level settings and may inherit shared settings from
+another profile with "inherits". The default profile is marked with "*", the
+profile in use with ">".
+
+Example:
+  imgood config profiles
+  imgood --profile prod ls`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := config.ListProfiles()
+		if err != nil {
+			return err
+		}
+		if len(profiles) == 0 {
+			fmt.Println("No profiles configured.")
+			return nil
+		}
+
+		fmt.Printf("  %-20s %-15s %-8s %s\n", "NAME", "INHERITS", "BACKEND", "LOCATION")
+		fmt.Println(strings.Repeat("-", 80))
+		for _, profile := range profiles {
+			marker := " "
+			if profile.Default {
+				marker = "*"
+			}
+			if profile.Name == config.ActiveProfile() {
+				marker = ">"
+			}
+
+			fmt.Printf("%s %-20s %-15s %-8s %s\n", marker, profile.Name, profile.Inherits, profile.Backend, profileLocation(profile))
+		}
+		return nil
+	},
+}
+
+// profileLocation describes where a profile stores objects
+func profileLocation(profile config.Profile) string {
+	if profile.Err != nil {
+		return "invalid: " + profile.Err.Error()
+	}
+	if profile.Backend == config.BackendLocal {
+		return profile.Root
+	}
+	bucket := profile.Bucket
+	if bucket == "" {
+		bucket = "-"
+	}
+	if profile.Endpoint != "" {
+		return bucket + " @ " + profile.Endpoint
+	}
+	return bucket
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptConfigValues asks for the values of the backend that are not already set
+func promptConfigValues(in io.Reader, values map[string]string) {
+	reader := bufio.NewReader(in)
+	eof := false
+	prompt := func(key, label, fallback string) {
+		if _, ok := values[key]; ok {
+			return
+		}
+		if eof {
+			values[key] = fallback
+			return
+		}
+		if fallback != "" {
+			fmt.Printf("%s [%s]: ", label, fallback)
+		} else {
+			fmt.Printf("%s: ", label)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// Input ended, use the defaults for the remaining values
+			eof = true
+			fmt.Println()
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			line = fallback
+		}
+		values[key] = line
+	}
+
+	prompt("backend", "Storage backend (s3 or local)", config.BackendS3)
+	if strings.ToLower(values["backend"]) == config.BackendLocal {
+		prompt("local.root", "Root directory", ".")
+		prompt("local.base_url", "Public base URL (empty for file:// URLs)", "")
+		return
+	}
+	prompt("s3.bucket", "Bucket", "")
+	prompt("s3.endpoint", "Endpoint URL (empty for AWS S3)", "")
+	prompt("s3.region", "Region", "us-east-1")
+	prompt("s3.access_key", "Access key (empty to use AWS credential files)", "")
+	prompt("s3.secret_key", "Secret key", "")
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configProfilesCmd)
+
+	// Define command line flags for config init
+	configInitCmd.Flags().StringVar(&configInitPath, "path", "", "Config file to create (default ~/.config/imgood/config.toml)")
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "Replace the config file if it already exists")
+	for _, f := range configInitFlags {
+		setting, _ := config.LookupSetting(f.key)
+		configInitValues[f.flag] = configInitCmd.Flags().String(f.flag, "", setting.Description)
+	}
+
+	_ = configInitCmd.RegisterFlagCompletionFunc("backend", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{config.BackendS3, config.BackendLocal}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
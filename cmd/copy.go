@@ -1,211 +1,297 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/h2non/bimg"
 	"github.com/spf13/cobra"
 
-	"github.com/mingeme/imgood/internal/config"
-	"github.com/mingeme/imgood/internal/s3"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/image"
+	"github.com/mingeme/imgood/internal/pool"
+	"github.com/mingeme/imgood/internal/storage"
 )
 
 var (
-	copySourceKey     string
+	copySourceKeys    []string
 	copyTargetKey     string
 	copyConvertFormat string
 	copyQuality       int
-	copyResize        string
+	copyResize        resizeFlags
 	copyOverwrite     bool
+	copyJobs          int
+	copyVariantFlags  variantFlags
+	copyTarget        targetFlags
+	copyWatermark     watermarkFlags
+	copyMeta          []string
 )
 
 var copyCmd = &cobra.Command{
-	Use:     "cp",
+	Use:     "cp [source keys...]",
 	Aliases: []string{"copy"},
-	Short:   "Copy an object in S3 with optional format conversion",
-	Long: `Copy an object in S3 with optional format conversion and resizing.
-	
+	Short:   "Copy objects in storage with optional format conversion",
+	Long: `Copy objects in storage with optional format conversion and resizing.
+
+Several source keys can be copied at once; each target key is then derived
+from its source key.
+
 Example:
-  imgood cp -s source.jpg -t target.webp -f webp -q 80 -r 800,600
-  imgood cp -s source.jpg -t existing.jpg --overwrite  # Overwrite existing file`,
-	Run: func(cmd *cobra.Command, args []string) {
+  imgood cp -s source.jpg -t target.webp -f webp -q 80 -r 800x600
+  imgood cp -s source.jpg -t existing.jpg --overwrite  # Overwrite existing file
+  imgood cp -f webp -j 8 images/a.jpg images/b.jpg images/c.jpg
+  imgood cp -s hero.jpg -f webp --variants 320,640,1280 --snippet picture`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources := append(append([]string(nil), copySourceKeys...), args...)
+
 		// Validate required parameters
-		if copySourceKey == "" {
-			fmt.Println("Error: Source key is required")
-			cmd.Help()
-			os.Exit(1)
+		if len(sources) == 0 {
+			return usageErrorf("source key is required")
 		}
-
-		// Get S3 configuration and create client
-		s3Config := config.GetS3Config()
-		s3Client, err := s3.NewClient(s3Config)
-		if err != nil {
-			fmt.Printf("Error: %s\n", err)
-			fmt.Println("Check your S3 configuration in config.toml or environment variables")
-			os.Exit(1)
+		if err := copyResize.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
 		}
-
-		// Check if source object exists
-		exists, err := s3Client.ObjectExists(copySourceKey)
-		if err != nil {
-			fmt.Printf("Error checking source object: %s\n", err)
-			os.Exit(1)
+		if err := copyVariantFlags.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
 		}
-		if !exists {
-			fmt.Printf("Error: Source object does not exist: %s\n", copySourceKey)
-			os.Exit(1)
+		if err := copyTarget.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
 		}
-
-		// Set default target key if not provided
-		if copyTargetKey == "" {
-			ext := filepath.Ext(copySourceKey)
-			baseName := strings.TrimSuffix(copySourceKey, ext)
-
-			// If format conversion is requested, change the extension
-			if copyConvertFormat != "" {
-				copyTargetKey = baseName + "." + copyConvertFormat
-			} else {
-				copyTargetKey = baseName + "-copy" + ext
-			}
+		if err := copyWatermark.validate(cmd); err != nil {
+			return errs.Wrap(errUsage, err)
 		}
-
-		// Check if source and target are the same
-		if copySourceKey == copyTargetKey {
-			fmt.Println("Error: Source and target keys cannot be the same")
-			os.Exit(1)
+		if copyTarget.enabled() && copyVariantFlags.enabled() {
+			return usageErrorf("--max-bytes and --target-ssim cannot be combined with --variants")
 		}
-
-		// Check if target already exists
-		exists, err = s3Client.ObjectExists(copyTargetKey)
-		if err != nil {
-			fmt.Printf("Error checking target object: %s\n", err)
-			os.Exit(1)
+		var targetFormat bimg.ImageType
+		if copyConvertFormat != "" {
+			format, err := image.ParseFormat(copyConvertFormat)
+			if err != nil {
+				return errs.Wrap(errUsage, err)
+			}
+			targetFormat = format
 		}
-		if exists && !copyOverwrite {
-			fmt.Printf("Error: Target object already exists: %s\n", copyTargetKey)
-			fmt.Println("Use --overwrite flag to overwrite existing objects")
-			os.Exit(1)
+		metadata, err := parseMetadata(copyMeta)
+		if err != nil {
+			return errs.Wrap(errUsage, err)
 		}
-		if exists && copyOverwrite {
-			fmt.Printf("Warning: Overwriting existing object: %s\n", copyTargetKey)
+		if copyTargetKey != "" && len(sources) > 1 {
+			return usageErrorf("--target can only be used with a single source key")
 		}
 
-		// Download the source object
-		fmt.Printf("Downloading object: %s\n", copySourceKey)
-		imageData, err := s3Client.GetObject(copySourceKey)
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
 		if err != nil {
-			fmt.Printf("Error downloading source object: %s\n", err)
-			os.Exit(1)
+			return err
 		}
 
-		// Get original image info
-		originalImage := bimg.NewImage(imageData)
-		size, err := originalImage.Size()
-		if err != nil {
-			fmt.Printf("Error getting image size: %s\n", err)
-			os.Exit(1)
-		}
-		imageType := bimg.DetermineImageType(imageData)
-		originalFormat := bimg.ImageTypeName(imageType)
-		fmt.Printf("Original image: %dx%d, %d bytes, format: %s\n",
-			size.Width, size.Height, len(imageData), originalFormat)
-
-		// Process the image if format conversion or resizing is requested
-		var outputData []byte
-		if copyConvertFormat != "" || copyResize != "" {
-			// Determine target format
-			var targetFormat bimg.ImageType
-			switch strings.ToLower(copyConvertFormat) {
-			case "webp":
-				targetFormat = bimg.WEBP
-			case "jpeg", "jpg":
-				targetFormat = bimg.JPEG
-			case "png":
-				targetFormat = bimg.PNG
-			case "":
-				// If no format specified but resizing is requested, keep original format
-				targetFormat = imageType
-			default:
-				fmt.Printf("Unsupported format: %s. Using original format.\n", copyConvertFormat)
-				targetFormat = imageType
-			}
+		// Copy objects concurrently, collecting failures instead of stopping the batch
+		w := humanOutput()
+		records := newRecordWriter()
+		summary := batchSummary{verb: "Copied", noun: "objects", total: len(sources)}
+		pool.Run(cmd.Context(), len(sources), copyJobs,
+			func(ctx context.Context, i int) jobResult {
+				var out bytes.Buffer
+				target := copyTargetKey
+				if target == "" {
+					target = copyDefaultTarget(sources[i], targetFormat)
+				}
+				objectRecords, err := copyObject(ctx, store, sources[i], target, targetFormat, metadata, &out)
+				result := jobResult{output: out.String(), err: err, records: objectRecords}
+				if len(objectRecords) > 0 {
+					// Variants are ordered by width, so the last one is the largest
+					result.url = objectRecords[len(objectRecords)-1].URL
+				}
+				return result
+			},
+			func(i int, result jobResult) {
+				if len(sources) > 1 {
+					fmt.Fprintf(w, "[%d/%d] %s\n", i+1, len(sources), sources[i])
+				}
+				fmt.Fprint(w, result.output)
+				records.writeAll(result.records)
+				summary.add(sources[i], result.err)
+				if result.err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", result.err)
+					return
+				}
+				fmt.Fprintf(w, "Successfully copied to: %s\n", result.url)
+			})
 
-			// Create options for processing
-			options := bimg.Options{
-				Quality:       copyQuality,
-				Type:          targetFormat,
-				NoProfile:     true,
-				StripMetadata: true,
-				NoAutoRotate:  false,
-			}
+		if len(sources) > 1 || cmd.Context().Err() != nil {
+			summary.print()
+		}
+		return summary.err()
+	},
+}
 
-			// Set width and height if provided
-			if copyResize != "" {
-				dimensions := strings.Split(copyResize, ",")
-				if len(dimensions) == 2 {
-					width, err := strconv.Atoi(strings.TrimSpace(dimensions[0]))
-					if err == nil && width > 0 {
-						options.Width = width
-					}
-
-					height, err := strconv.Atoi(strings.TrimSpace(dimensions[1]))
-					if err == nil && height > 0 {
-						options.Height = height
-					}
-				}
-			}
+// copyDefaultTarget derives the target key for a source key when --target is not given
+func copyDefaultTarget(sourceKey string, targetFormat bimg.ImageType) string {
+	ext := filepath.Ext(sourceKey)
+	baseName := strings.TrimSuffix(sourceKey, ext)
 
-			// Process the image
-			outputData, err = originalImage.Process(options)
-			if err != nil {
-				fmt.Printf("Error processing image: %s\n", err)
-				os.Exit(1)
-			}
+	// If format conversion is requested, change the extension
+	if targetFormat != bimg.UNKNOWN {
+		return baseName + "." + image.Extension(targetFormat)
+	}
+	return baseName + "-copy" + ext
+}
 
-			newFormat := bimg.ImageTypeName(targetFormat)
-			fmt.Printf("Converted image: %d bytes, format: %s (%.2f%% of original)\n",
-				len(outputData), newFormat, float64(len(outputData))/float64(len(imageData))*100)
-		} else {
-			// No conversion needed, use original data
-			outputData = imageData
-			fmt.Println("No conversion requested, copying original image")
+// copyObject copies sourceKey to targetKey, converting the image to targetFormat unless it is
+// bimg.UNKNOWN and replacing its metadata if given, and returns records of the stored objects
+func copyObject(ctx context.Context, store storage.Storage, sourceKey, targetKey string, targetFormat bimg.ImageType, metadata map[string]string, out io.Writer) ([]outputRecord, error) {
+	// Check if source object exists
+	exists, err := storage.Exists(ctx, store, sourceKey)
+	if err != nil {
+		return nil, fmt.Errorf("error checking source object: %w", err)
+	}
+	if !exists {
+		return nil, errs.Wrap(errs.ErrNotFound, fmt.Errorf("source object does not exist: %s", sourceKey))
+	}
+
+	// Check if source and target are the same
+	if sourceKey == targetKey {
+		return nil, usageErrorf("source and target keys cannot be the same")
+	}
+
+	// Check if target already exists
+	exists, err = storage.Exists(ctx, store, targetKey)
+	if err != nil {
+		return nil, fmt.Errorf("error checking target object: %w", err)
+	}
+	if exists && !copyOverwrite {
+		return nil, errs.Wrap(errs.ErrConflict, fmt.Errorf("target object already exists: %s (use --overwrite flag to overwrite existing objects)", targetKey))
+	}
+	if exists && copyOverwrite {
+		fmt.Fprintf(out, "Warning: Overwriting existing object: %s\n", targetKey)
+	}
+
+	// Copy server-side, keeping the metadata, when the object is not transformed. A watermark
+	// configured for the profile is drawn only when the image is re-encoded anyway.
+	convert := copyConvertFormat != "" || copyResize.enabled() || copyTarget.enabled() || copyWatermark.requested()
+	if !convert && !copyVariantFlags.enabled() && len(metadata) == 0 {
+		fmt.Fprintln(out, "No conversion requested, copying object server-side")
+		if copyWatermark.enabled() {
+			fmt.Fprintln(out, "The configured watermark is not drawn, pass --watermark-text or --watermark-image to draw one")
 		}
+		if err := storage.Copy(ctx, store, sourceKey, targetKey); err != nil {
+			return nil, err
+		}
+		return []outputRecord{objectRecord(ctx, store, targetKey, store.URL(targetKey), statusCopied)}, nil
+	}
+
+	// Download the source object
+	fmt.Fprintf(out, "Downloading object: %s\n", sourceKey)
+	imageData, err := storage.GetBytes(ctx, store, sourceKey)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading source object: %w", err)
+	}
+
+	putOpts := sourceOptions(path.Base(sourceKey), imageData, metadata)
+	// Guards against an object created at the target since the check above
+	putOpts.IfNotExists = !copyOverwrite
 
-		// Upload to target key
-		fmt.Printf("Uploading to: %s\n", copyTargetKey)
-		err = s3Client.UploadFile(copyTargetKey, outputData)
+	// Get original image info
+	originalImage := bimg.NewImage(imageData)
+	size, err := originalImage.Size()
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error getting image size: %w", err))
+	}
+	imageType := bimg.DetermineImageType(imageData)
+	originalFormat := bimg.ImageTypeName(imageType)
+	fmt.Fprintf(out, "Original image: %dx%d, %d bytes, format: %s\n",
+		size.Width, size.Height, len(imageData), originalFormat)
+
+	// If no format specified but resizing is requested, keep original format
+	if targetFormat == bimg.UNKNOWN {
+		targetFormat = imageType
+	}
+
+	// Upload one image per width instead of a single image when variants are requested
+	if copyVariantFlags.enabled() {
+		processor, err := image.NewProcessorFromBuffer(imageData)
 		if err != nil {
-			fmt.Printf("Error uploading to S3: %s\n", err)
-			os.Exit(1)
+			return nil, err
+		}
+		processOpts := image.ProcessOptions{
+			Quality:   copyQuality,
+			Format:    targetFormat,
+			Watermark: copyWatermark.value,
 		}
+		records, err := uploadVariants(ctx, store, processor, processOpts, targetKey, &copyVariantFlags, putOpts, int64(len(imageData)), out)
+		for i := range records {
+			records[i].Status = statusCopied
+		}
+		return records, err
+	}
 
-		// Get and display the file URL
-		s3URL := s3Client.GetFileURL(copyTargetKey)
-		fmt.Printf("Successfully copied to: %s\n", s3URL)
-	},
+	// Only the metadata changes, upload the original bytes
+	if !convert {
+		if copyWatermark.enabled() {
+			fmt.Fprintln(out, "The configured watermark is not drawn, pass --watermark-text or --watermark-image to draw one")
+		}
+		fmt.Fprintf(out, "Uploading to: %s\n", targetKey)
+		if err := store.Put(ctx, targetKey, bytes.NewReader(imageData), imageOptions(putOpts, targetKey, imageData)); err != nil {
+			return nil, fmt.Errorf("error uploading object: %w", err)
+		}
+		return []outputRecord{imageRecord(ctx, store, targetKey, store.URL(targetKey), imageData, int64(len(imageData)), statusCopied)}, nil
+	}
+
+	// Process the image
+	processor, err := image.NewProcessorFromBuffer(imageData)
+	if err != nil {
+		return nil, err
+	}
+	outputData, enc, err := copyTarget.process(processor, image.ProcessOptions{
+		Quality:   copyQuality,
+		Resize:    copyResize.value,
+		Format:    targetFormat,
+		Watermark: copyWatermark.value,
+	}, out)
+	if err != nil {
+		return nil, err
+	}
+
+	newFormat := bimg.ImageTypeName(targetFormat)
+	fmt.Fprintf(out, "Converted image: %d bytes, format: %s (%.2f%% of original)\n",
+		len(outputData), newFormat, float64(len(outputData))/float64(len(imageData))*100)
+
+	// Upload to target key
+	fmt.Fprintf(out, "Uploading to: %s\n", targetKey)
+	if err := store.Put(ctx, targetKey, bytes.NewReader(outputData), imageOptions(putOpts, targetKey, outputData)); err != nil {
+		return nil, fmt.Errorf("error uploading object: %w", err)
+	}
+
+	record := imageRecord(ctx, store, targetKey, store.URL(targetKey), outputData, int64(len(imageData)), statusCopied)
+	enc.apply(&record)
+	return []outputRecord{record}, nil
 }
 
 func init() {
 	rootCmd.AddCommand(copyCmd)
 
 	// Define command line flags for copy operation
-	copyCmd.Flags().StringVarP(&copySourceKey, "source", "s", "", "Source S3 object key to copy (required)")
-	copyCmd.Flags().StringVarP(&copyTargetKey, "target", "t", "", "Target S3 object key (destination)")
-	copyCmd.Flags().StringVarP(&copyConvertFormat, "format", "f", "", "Convert to format (webp, jpeg, png)")
+	copyCmd.Flags().StringArrayVarP(&copySourceKeys, "source", "s", nil, "Source object key to copy (repeatable)")
+	copyCmd.Flags().StringVarP(&copyTargetKey, "target", "t", "", "Target object key (destination), only for a single source key")
+	copyCmd.Flags().StringVarP(&copyConvertFormat, "format", "f", "", "Convert to format ("+strings.Join(image.FormatNames(), ", ")+")")
 	copyCmd.Flags().IntVarP(&copyQuality, "quality", "q", 80, "Quality of the converted image (1-100)")
-	copyCmd.Flags().StringVarP(&copyResize, "resize", "r", "", "Resize image to width,height (e.g., '800,600'). Use 0 for any dimension to maintain aspect ratio")
-
-	// Mark required flags
-	copyCmd.MarkFlagRequired("source")
+	copyCmd.Flags().IntVarP(&copyJobs, "jobs", "j", defaultJobs, "Number of objects to copy concurrently")
 
 	copyCmd.Flags().BoolVar(&copyOverwrite, "overwrite", false, "Overwrite target object if it already exists")
+	addResizeFlags(copyCmd, &copyResize)
+	addMetadataFlag(copyCmd, &copyMeta)
+	addVariantFlags(copyCmd, &copyVariantFlags)
+	addTargetFlags(copyCmd, &copyTarget)
+	addWatermarkFlags(copyCmd, &copyWatermark)
 
 	// Add shell completion for flags
 	_ = copyCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"webp", "jpeg", "jpg", "png"}, cobra.ShellCompDirectiveNoFileComp
+		return image.FormatNames(), cobra.ShellCompDirectiveNoFileComp
 	})
 }
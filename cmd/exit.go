@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/errs"
+)
+
+// Exit codes, documented in the README
+const (
+	exitError        = 1
+	exitUsage        = 2
+	exitConfig       = 3
+	exitNotFound     = 4
+	exitConflict     = 5
+	exitAuth         = 6
+	exitNetwork      = 7
+	exitInvalidImage = 8
+	exitInterrupted  = 130
+)
+
+// errUsage marks invalid flags and arguments
+var errUsage = errors.New("usage error")
+
+// usageErrorf returns an error for invalid flags or arguments
+func usageErrorf(format string, args ...any) error {
+	return errs.Wrap(errUsage, fmt.Errorf(format, args...))
+}
+
+// usageArgs reports the errors of an argument validator as usage errors
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		return errs.Wrap(errUsage, validate(cmd, args))
+	}
+}
+
+// reportedError wraps an error that was already printed, like the failures listed in a
+// batch summary, so Execute only uses it to choose the exit code
+type reportedError struct {
+	err error
+}
+
+// Error returns the message of the wrapped error
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code for err. When err joins several errors, like the
+// failures of a batch, the first category in the order below decides.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, errs.ErrConfig):
+		return exitConfig
+	case errors.Is(err, errs.ErrAuth), errors.Is(err, fs.ErrPermission):
+		return exitAuth
+	case errors.Is(err, errs.ErrNetwork):
+		return exitNetwork
+	case errors.Is(err, errs.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, errs.ErrConflict):
+		return exitConflict
+	case errors.Is(err, errs.ErrInvalidImage):
+		return exitInvalidImage
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/h2non/bimg"
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/image"
+	"github.com/mingeme/imgood/internal/pool"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+// timeResolution is the precision used to compare local and remote modification times
+const timeResolution = time.Second
+
+var (
+	getDir          string
+	getFormat       string
+	getQuality      int
+	getResize       resizeFlags
+	getKeepMetadata bool
+	getForce        bool
+	getSkipExisting bool
+	getJobs         int
+)
+
+var getCmd = &cobra.Command{
+	Use:     "get <keys or prefixes...>",
+	Aliases: []string{"download"},
+	Short:   "Download objects from storage to local disk",
+	Long: `Download objects from storage to local disk, preserving the key hierarchy
+below the target directory.
+
+Arguments ending in "/" download every object below that prefix. Files that
+already match the object (same size and modification time, and same MD5 when
+the ETag provides one) are skipped. Images can be converted or resized on the
+way down with the same options as "up". Converted or resized files are always
+downloaded again, since the options may have changed, unless --skip-existing
+is given.
+
+Example:
+  imgood get images/a.jpg
+  imgood get images/2026/ -d ./backup
+  imgood get images/2026/ -d ./thumbs -f webp -r 320x`,
+	Args: usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var targetFormat bimg.ImageType
+		if getFormat != "" {
+			format, err := image.ParseFormat(getFormat)
+			if err != nil {
+				return errs.Wrap(errUsage, err)
+			}
+			targetFormat = format
+		}
+		if err := getResize.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+		if getForce && getSkipExisting {
+			return usageErrorf("--force cannot be combined with --skip-existing")
+		}
+
+		// Downloads are written through a local storage rooted at --dir
+		dest, err := storage.NewLocal(config.LocalConfig{Root: getDir})
+		if err != nil {
+			return err
+		}
+
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		// Resolve keys and prefixes into objects
+		objects, err := collectGetObjects(cmd.Context(), store, args)
+		if err != nil {
+			return err
+		}
+		w := humanOutput()
+		records := newRecordWriter()
+		if len(objects) == 0 {
+			fmt.Fprintln(w, "No objects found.")
+			return nil
+		}
+
+		// Download objects concurrently, collecting failures instead of stopping the batch
+		summary := batchSummary{verb: "Downloaded", noun: "objects", total: len(objects)}
+		pool.Run(cmd.Context(), len(objects), getJobs,
+			func(ctx context.Context, i int) jobResult {
+				var out bytes.Buffer
+				record, err := getObject(ctx, store, dest, objects[i], targetFormat, &out)
+				result := jobResult{output: out.String(), url: record.Path, skipped: record.Status == statusSkipped, err: err}
+				if err == nil {
+					result.records = []outputRecord{record}
+				}
+				return result
+			},
+			func(i int, result jobResult) {
+				if len(objects) > 1 {
+					fmt.Fprintf(w, "[%d/%d] %s\n", i+1, len(objects), objects[i].Key)
+				}
+				fmt.Fprint(w, result.output)
+				records.writeAll(result.records)
+				if result.skipped {
+					summary.skip()
+					fmt.Fprintf(w, "Up to date, skipped: %s\n", result.url)
+					return
+				}
+				summary.add(objects[i].Key, result.err)
+				if result.err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", result.err)
+					return
+				}
+				fmt.Fprintf(w, "Saved to: %s\n", result.url)
+			})
+
+		if len(objects) > 1 || cmd.Context().Err() != nil {
+			summary.print()
+		}
+		return summary.err()
+	},
+}
+
+// collectGetObjects resolves keys and prefixes (arguments ending in "/") into objects
+func collectGetObjects(ctx context.Context, store storage.Storage, args []string) ([]storage.Object, error) {
+	var objects []storage.Object
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+		if !strings.HasSuffix(arg, "/") {
+			obj, err := store.Head(ctx, arg)
+			if err != nil {
+				return nil, fmt.Errorf("error getting object %s: %w", arg, err)
+			}
+			if !seen[obj.Key] {
+				seen[obj.Key] = true
+				objects = append(objects, obj)
+			}
+			continue
+		}
+
+		err := store.List(ctx, arg, 0, func(obj storage.Object) error {
+			// Folder markers such as "images/" would collide with the directory of their children
+			if strings.HasSuffix(obj.Key, "/") {
+				return nil
+			}
+			if !seen[obj.Key] {
+				seen[obj.Key] = true
+				objects = append(objects, obj)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// getObject downloads an object into dest, processing it if requested, and returns a record
+// of the local file, with status skipped when the file was already up to date
+func getObject(ctx context.Context, store storage.Storage, dest *storage.Local, obj storage.Object, targetFormat bimg.ImageType, out io.Writer) (outputRecord, error) {
+	process := getFormat != "" || getResize.enabled()
+
+	localKey := obj.Key
+	if targetFormat != bimg.UNKNOWN {
+		localKey = strings.TrimSuffix(localKey, path.Ext(localKey)) + "." + image.Extension(targetFormat)
+	}
+
+	// Keys like "../x" are rejected so nothing is written outside --dir
+	localPath, err := dest.Path(localKey)
+	if err != nil {
+		return outputRecord{}, err
+	}
+	record := outputRecord{Key: obj.Key, Path: localPath, LastModified: formatRecordTime(obj.LastModified), Status: statusDownloaded}
+	if !process {
+		// Unprocessed files hold the stored content
+		record.Size, record.ETag = recordSize(obj.Size), obj.ETag
+	}
+
+	// Skip files that are already up to date. A processed file may have been written with
+	// other options, so it is only kept when asked to.
+	if !getForce && (!process || getSkipExisting) && localFileMatches(localPath, obj, process) {
+		record.Status = statusSkipped
+		return record, nil
+	}
+
+	body, err := store.Get(ctx, obj.Key)
+	if err != nil {
+		return outputRecord{}, err
+	}
+	defer body.Close()
+
+	// Unprocessed objects are streamed to disk without holding them in memory
+	var content io.Reader = body
+	if process {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return outputRecord{}, fmt.Errorf("error reading object %s: %w", obj.Key, err)
+		}
+
+		processor, err := image.NewProcessorFromBuffer(data)
+		if err != nil {
+			return outputRecord{}, err
+		}
+
+		width0, height0, size, format := processor.GetOriginalInfo()
+		fmt.Fprintf(out, "Original image: %dx%d, %d bytes, format: %s\n", width0, height0, size, format)
+
+		processOpts := image.ProcessOptions{
+			Quality:      getQuality,
+			Format:       targetFormat,
+			KeepMetadata: getKeepMetadata,
+		}
+		if targetFormat == bimg.UNKNOWN {
+			processOpts.Format = bimg.DetermineImageType(data)
+		}
+		processOpts.Resize = getResize.value
+
+		data, err = processor.Process(processOpts)
+		if err != nil {
+			return outputRecord{}, err
+		}
+		fmt.Fprintf(out, "Converted image: %d bytes (%.2f%% of original)\n",
+			len(data), float64(len(data))/float64(size)*100)
+		content = bytes.NewReader(data)
+
+		processed := encodedRecord(obj.Key, data, obj.Size, statusDownloaded)
+		processed.Path, processed.LastModified = localPath, record.LastModified
+		record = processed
+	}
+
+	if err := dest.Put(ctx, localKey, content, storage.PutOptions{}); err != nil {
+		return outputRecord{}, err
+	}
+
+	// Use the object's modification time so later runs can detect unchanged files
+	if !obj.LastModified.IsZero() {
+		if err := os.Chtimes(localPath, obj.LastModified, obj.LastModified); err != nil {
+			return outputRecord{}, fmt.Errorf("error setting file time: %w", err)
+		}
+	}
+
+	return record, nil
+}
+
+// localFileMatches reports whether the local file already holds the object's content.
+// Processed files are only compared by modification time since their size differs, which
+// does not tell whether they were processed with the same options.
+func localFileMatches(localPath string, obj storage.Object, processed bool) bool {
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if !info.ModTime().Truncate(timeResolution).Equal(obj.LastModified.Truncate(timeResolution)) {
+		return false
+	}
+	if processed {
+		return true
+	}
+	if info.Size() != obj.Size {
+		return false
+	}
+
+	// Multipart ETags contain a "-" and are not the MD5 of the content
+	if obj.ETag == "" || strings.Contains(obj.ETag, "-") {
+		return true
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == obj.ETag
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+
+	// Define command line flags for download operation
+	getCmd.Flags().StringVarP(&getDir, "dir", "d", ".", "Local directory to download into")
+	getCmd.Flags().StringVarP(&getFormat, "format", "f", "", "Convert to format ("+strings.Join(image.FormatNames(), ", ")+")")
+	getCmd.Flags().IntVarP(&getQuality, "quality", "q", 80, "Quality of the converted image (1-100)")
+	addResizeFlags(getCmd, &getResize)
+	getCmd.Flags().BoolVar(&getKeepMetadata, "keep-metadata", false, "Keep image metadata (EXIF, etc.) when converting")
+	getCmd.Flags().BoolVar(&getForce, "force", false, "Download even if the local file is up to date")
+	getCmd.Flags().BoolVar(&getSkipExisting, "skip-existing", false, "Keep converted or resized files with the object's modification time")
+	getCmd.Flags().IntVarP(&getJobs, "jobs", "j", defaultJobs, "Number of objects to download concurrently")
+
+	// Add shell completion for flags
+	_ = getCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return image.FormatNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = getCmd.RegisterFlagCompletionFunc("dir", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	})
+}
@@ -0,0 +1,475 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/h2non/bimg"
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/image"
+)
+
+// resizeFlags holds the resize options shared by the commands that process images
+type resizeFlags struct {
+	spec      string
+	mode      string
+	gravity   string
+	noUpscale bool
+
+	// value is the resize parsed by validate
+	value image.Resize
+}
+
+// addResizeFlags registers the resize flags on cmd
+func addResizeFlags(cmd *cobra.Command, r *resizeFlags) {
+	cmd.Flags().StringVarP(&r.spec, "resize", "r", "", "Resize image: WxH to fit within, Wx or xH for one side, WxH^ to fill, max:N to limit the longest side")
+	cmd.Flags().StringVar(&r.mode, "resize-mode", "", "Resize mode: "+strings.Join(image.ResizeModes, ", ")+" (default fit, or fill for WxH^)")
+	cmd.Flags().StringVar(&r.gravity, "gravity", string(image.GravityCenter), "Part of the image kept by --resize-mode crop: "+strings.Join(image.Gravities, ", "))
+	cmd.Flags().BoolVar(&r.noUpscale, "no-upscale", false, "Never enlarge images smaller than the resize target")
+
+	_ = cmd.RegisterFlagCompletionFunc("resize-mode", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return image.ResizeModes, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = cmd.RegisterFlagCompletionFunc("gravity", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return image.Gravities, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// enabled reports whether resizing was requested
+func (r *resizeFlags) enabled() bool {
+	return r.spec != ""
+}
+
+// validate checks the resize flags before any work is started and keeps the parsed resize
+func (r *resizeFlags) validate() error {
+	resize, err := image.ParseResize(r.spec)
+	if err != nil {
+		return err
+	}
+	gravity, err := image.ParseGravity(r.gravity)
+	if err != nil {
+		return err
+	}
+
+	if r.mode != "" {
+		mode, err := image.ParseResizeMode(r.mode)
+		if err != nil {
+			return err
+		}
+		if resize.Max > 0 && mode != image.ResizeFit {
+			return fmt.Errorf("--resize-mode %s needs a WxH size, max:N always fits", mode)
+		}
+		if mode == image.ResizeCrop && (resize.Width == 0 || resize.Height == 0) {
+			return fmt.Errorf("--resize-mode crop needs both width and height")
+		}
+		resize.Mode = mode
+	}
+	if gravity != image.GravityCenter && resize.Mode != image.ResizeCrop {
+		return fmt.Errorf("--gravity only applies to --resize-mode crop")
+	}
+
+	resize.Gravity = gravity
+	resize.NoUpscale = resize.NoUpscale || r.noUpscale
+	r.value = resize
+	return nil
+}
+
+// targetFlags holds the options shared by up and cp that search the quality for a size
+// budget or a visual similarity target
+type targetFlags struct {
+	maxBytes   string
+	targetSSIM float64
+	minQuality int
+	downscale  bool
+
+	// budget is the size budget parsed by validate
+	budget image.Budget
+}
+
+// addTargetFlags registers the size budget and similarity target flags on cmd
+func addTargetFlags(cmd *cobra.Command, t *targetFlags) {
+	cmd.Flags().StringVar(&t.maxBytes, "max-bytes", "", "Largest size of the stored image (e.g., '200KB'), lowering the quality until it fits")
+	cmd.Flags().Float64Var(&t.targetSSIM, "target-ssim", 0, "Lowest SSIM compared to the original (e.g., 0.98), using the lowest quality that reaches it")
+	cmd.Flags().IntVar(&t.minQuality, "min-quality", 30, "Lowest quality tried by --max-bytes and --target-ssim")
+	cmd.Flags().BoolVar(&t.downscale, "downscale", false, "Reduce the dimensions when --max-bytes is not reached at --min-quality")
+}
+
+// enabled reports whether the quality is searched instead of given
+func (t *targetFlags) enabled() bool {
+	return t.maxBytes != "" || t.targetSSIM != 0
+}
+
+// validate checks the target flags before any work is started and keeps the parsed budget
+func (t *targetFlags) validate() error {
+	if t.maxBytes != "" && t.targetSSIM != 0 {
+		return fmt.Errorf("--max-bytes cannot be combined with --target-ssim")
+	}
+	if t.targetSSIM != 0 && (t.targetSSIM <= 0 || t.targetSSIM > 1) {
+		return fmt.Errorf("--target-ssim must be greater than 0 and at most 1")
+	}
+	if t.downscale && t.maxBytes == "" {
+		return fmt.Errorf("--downscale requires --max-bytes")
+	}
+	if t.minQuality < 1 || t.minQuality > 100 {
+		return fmt.Errorf("--min-quality must be between 1 and 100")
+	}
+	if t.maxBytes == "" {
+		return nil
+	}
+
+	maxBytes, err := parseByteSize(t.maxBytes)
+	if err != nil {
+		return fmt.Errorf("invalid --max-bytes: %w", err)
+	}
+	if maxBytes == 0 {
+		return fmt.Errorf("--max-bytes must be greater than 0")
+	}
+	t.budget = image.Budget{MaxBytes: maxBytes, MinQuality: t.minQuality, Downscale: t.downscale}
+	return nil
+}
+
+// encoding describes how a processed image was encoded, for records
+type encoding struct {
+	quality int
+	ssim    float64
+	// missed reports that the similarity target was not reached
+	missed bool
+	// candidates holds the size of each format tried by --format auto
+	candidates map[string]int64
+}
+
+// apply adds the encoding to a record
+func (e encoding) apply(record *outputRecord) {
+	record.Quality = e.quality
+	record.SSIM = math.Round(e.ssim*10000) / 10000
+	record.Candidates = e.candidates
+}
+
+// process encodes the image, searching the quality for the size budget or similarity
+// target when one was given, and prints the result
+func (t *targetFlags) process(processor *image.Processor, opts image.ProcessOptions, out io.Writer) ([]byte, encoding, error) {
+	switch {
+	case t.maxBytes != "":
+		result, err := processor.ProcessWithin(opts, t.budget)
+		if errors.Is(err, image.ErrBudgetUnreachable) && !t.downscale {
+			return nil, encoding{}, fmt.Errorf("%w (use --downscale to reduce the dimensions)", err)
+		}
+		if err != nil {
+			return nil, encoding{}, err
+		}
+
+		fmt.Fprintf(out, "Fitted into %s: %d bytes at quality %d", formatBytes(t.budget.MaxBytes), len(result.Data), result.Quality)
+		if result.Downscaled {
+			fmt.Fprintf(out, ", downscaled to %dx%d", result.Width, result.Height)
+		}
+		fmt.Fprintln(out)
+		return result.Data, encoding{quality: result.Quality}, nil
+
+	case t.targetSSIM != 0:
+		result, err := processor.ProcessSimilar(opts, t.targetSSIM, t.minQuality)
+		if err != nil {
+			return nil, encoding{}, err
+		}
+
+		if result.Reached {
+			fmt.Fprintf(out, "Reached SSIM %.4f (target %.4f): %d bytes at quality %d\n", result.SSIM, t.targetSSIM, len(result.Data), result.Quality)
+		} else {
+			fmt.Fprintf(out, "Warning: SSIM %.4f is below the target %.4f even at quality %d, %d bytes\n", result.SSIM, t.targetSSIM, result.Quality, len(result.Data))
+		}
+		return result.Data, encoding{quality: result.Quality, ssim: result.SSIM, missed: !result.Reached}, nil
+
+	default:
+		data, err := processor.Process(opts)
+		return data, encoding{}, err
+	}
+}
+
+// originalCandidate names the unchanged original among the candidates of --format auto
+const originalCandidate = "original"
+
+// autoFormats resolves the candidates of --format auto from a comma-separated list, the
+// auto_formats setting or the defaults. Default formats that libvips cannot encode are
+// skipped, configured ones are rejected.
+func autoFormats(names string) ([]bimg.ImageType, error) {
+	configured := config.GetAutoFormats()
+	if names != "" {
+		configured = strings.Split(names, ",")
+	}
+
+	var formats []bimg.ImageType
+	for _, name := range configured {
+		format, err := image.ParseFormat(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	if configured != nil {
+		if len(formats) == 0 {
+			return nil, fmt.Errorf("no candidate formats given for --format auto")
+		}
+		return formats, nil
+	}
+
+	for _, name := range image.DefaultAutoFormats {
+		if format, err := image.ParseFormat(name); err == nil {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("none of the formats of --format auto (%s) is supported by the installed libvips", strings.Join(image.DefaultAutoFormats, ", "))
+	}
+	return formats, nil
+}
+
+// encodeSmallest encodes the image to each candidate format, searching the quality for
+// the target options, and returns the smallest encoding that meets them with its format.
+// With keepOriginal the original data is returned as bimg.UNKNOWN when no candidate is
+// smaller. The candidates and the decision are printed.
+func (t *targetFlags) encodeSmallest(processor *image.Processor, opts image.ProcessOptions, formats []bimg.ImageType, keepOriginal bool, out io.Writer) ([]byte, bimg.ImageType, encoding, error) {
+	var (
+		best       []byte
+		bestFormat bimg.ImageType
+		bestEnc    encoding
+		failures   []error
+	)
+	sizes := make(map[string]int64, len(formats)+1)
+	for _, format := range formats {
+		name := image.Extension(format)
+		if format == bimg.JPEG && processor.HasAlpha() {
+			fmt.Fprintf(out, "Candidate %s: skipped, the image has transparency\n", name)
+			continue
+		}
+
+		candidateOpts := opts
+		candidateOpts.Format = format
+		data, enc, err := t.process(processor, candidateOpts, io.Discard)
+		if err != nil {
+			fmt.Fprintf(out, "Candidate %s: %s\n", name, err)
+			failures = append(failures, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		sizes[name] = int64(len(data))
+
+		fmt.Fprintf(out, "Candidate %s: %d bytes", name, len(data))
+		if enc.quality > 0 {
+			fmt.Fprintf(out, " at quality %d", enc.quality)
+		}
+		if enc.ssim > 0 {
+			fmt.Fprintf(out, ", SSIM %.4f", enc.ssim)
+		}
+		if enc.missed {
+			fmt.Fprint(out, ", below the target")
+		}
+		fmt.Fprintln(out)
+
+		// Candidates meeting the similarity target beat smaller ones that miss it
+		better := best == nil || (bestEnc.missed && !enc.missed) ||
+			(bestEnc.missed == enc.missed && len(data) < len(best))
+		if better {
+			best, bestFormat, bestEnc = data, format, enc
+		}
+	}
+
+	original := processor.GetOriginalBuffer()
+	if keepOriginal {
+		sizes[originalCandidate] = int64(len(original))
+	}
+	if keepOriginal && (best == nil || bestEnc.missed || len(original) <= len(best)) &&
+		(t.maxBytes == "" || int64(len(original)) <= t.budget.MaxBytes) {
+		fmt.Fprintf(out, "Kept original: %d bytes, no candidate is smaller\n", len(original))
+		return original, bimg.UNKNOWN, encoding{candidates: sizes}, nil
+	}
+	if best == nil {
+		return nil, bimg.UNKNOWN, encoding{}, errors.Join(failures...)
+	}
+
+	fmt.Fprintf(out, "Chose %s: %d bytes\n", image.Extension(bestFormat), len(best))
+	bestEnc.candidates = sizes
+	return best, bestFormat, bestEnc, nil
+}
+
+// byteUnits maps the units of a byte size to their multiplier. Units are binary, as
+// for the sizes in the configuration file.
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+}
+
+// parseByteSize parses a size such as "200KB", "1.5MB" or "4096"
+func parseByteSize(value string) (int64, error) {
+	number := strings.TrimRightFunc(strings.TrimSpace(value), unicode.IsLetter)
+	unit := strings.ToLower(strings.TrimSpace(value)[len(number):])
+
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in %s (expected B, KB, MB or GB)", value)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n < 0 || n*float64(multiplier) >= math.MaxInt64 {
+		return 0, fmt.Errorf("%s is not a size", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// watermarkFlags holds the watermark options shared by up and cp
+type watermarkFlags struct {
+	text     string
+	image    string
+	font     string
+	size     int
+	color    string
+	scale    float64
+	opacity  float64
+	position string
+	margin   int
+	none     bool
+
+	// value is the watermark parsed by validate
+	value image.Watermark
+	// fromFlags is set by validate when the text or image was given on the command line
+	fromFlags bool
+}
+
+// addWatermarkFlags registers the watermark flags on cmd
+func addWatermarkFlags(cmd *cobra.Command, w *watermarkFlags) {
+	cmd.Flags().StringVar(&w.text, "watermark-text", "", "Text drawn over the image")
+	cmd.Flags().StringVar(&w.image, "watermark-image", "", "Local image, usually a PNG logo, drawn over the image")
+	cmd.Flags().StringVar(&w.font, "watermark-font", "sans", "Font of the watermark text (e.g., 'sans bold')")
+	cmd.Flags().IntVar(&w.size, "watermark-size", 0, "Height of the watermark text in pixels, 0 for 1/30 of the image width")
+	cmd.Flags().StringVar(&w.color, "watermark-color", "#ffffff", "Color of the watermark text (#rrggbb)")
+	cmd.Flags().Float64Var(&w.scale, "watermark-scale", 0.2, "Width of the watermark image relative to the image width")
+	cmd.Flags().Float64Var(&w.opacity, "watermark-opacity", 0.5, "Opacity of the watermark from 0 to 1")
+	cmd.Flags().StringVar(&w.position, "watermark-position", string(image.PositionSouthEast), "Position of the watermark: "+strings.Join(image.Positions, ", "))
+	cmd.Flags().IntVar(&w.margin, "watermark-margin", 20, "Distance of the watermark to the image edges in pixels")
+	cmd.Flags().BoolVar(&w.none, "no-watermark", false, "Do not draw the watermark configured in the profile")
+
+	_ = cmd.RegisterFlagCompletionFunc("watermark-position", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return image.Positions, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = cmd.RegisterFlagCompletionFunc("watermark-image", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"png"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+}
+
+// enabled reports whether a watermark is drawn
+func (w *watermarkFlags) enabled() bool {
+	return !w.value.IsZero()
+}
+
+// requested reports whether a watermark was given on the command line rather than
+// only configured for the profile
+func (w *watermarkFlags) requested() bool {
+	return w.enabled() && w.fromFlags
+}
+
+// validate fills the flags that were not given from the watermark settings of the
+// configuration, checks them before any work is started, and keeps the parsed watermark
+// with the loaded image
+func (w *watermarkFlags) validate(cmd *cobra.Command) error {
+	if w.none {
+		if cmd.Flags().Changed("watermark-text") || cmd.Flags().Changed("watermark-image") {
+			return fmt.Errorf("--no-watermark cannot be combined with --watermark-text or --watermark-image")
+		}
+		w.value = image.Watermark{}
+		return nil
+	}
+
+	w.fromFlags = cmd.Flags().Changed("watermark-text") || cmd.Flags().Changed("watermark-image")
+
+	// Flags take precedence over the configuration
+	defaults := config.GetWatermarkConfig()
+	useDefault := func(flag string, configured bool) bool {
+		return configured && !cmd.Flags().Changed(flag)
+	}
+	if useDefault("watermark-text", defaults.Text != "") {
+		w.text = defaults.Text
+	}
+	if useDefault("watermark-image", defaults.Image != "") {
+		w.image = defaults.Image
+	}
+	if useDefault("watermark-font", defaults.Font != "") {
+		w.font = defaults.Font
+	}
+	if useDefault("watermark-size", defaults.Size != 0) {
+		w.size = defaults.Size
+	}
+	if useDefault("watermark-color", defaults.Color != "") {
+		w.color = defaults.Color
+	}
+	if useDefault("watermark-scale", defaults.Scale != 0) {
+		w.scale = defaults.Scale
+	}
+	if useDefault("watermark-opacity", defaults.Opacity != 0) {
+		w.opacity = defaults.Opacity
+	}
+	if useDefault("watermark-position", defaults.Position != "") {
+		w.position = defaults.Position
+	}
+	if useDefault("watermark-margin", defaults.Margin != 0) {
+		w.margin = defaults.Margin
+	}
+
+	if w.text == "" && w.image == "" {
+		return nil
+	}
+	color, err := image.ParseColor(w.color)
+	if err != nil {
+		return err
+	}
+	position, err := image.ParsePosition(w.position)
+	if err != nil {
+		return err
+	}
+	if w.size < 0 || w.margin < 0 {
+		return fmt.Errorf("--watermark-size and --watermark-margin cannot be negative")
+	}
+	if w.opacity <= 0 || w.opacity > 1 {
+		return fmt.Errorf("--watermark-opacity must be greater than 0 and at most 1")
+	}
+	if w.scale <= 0 || w.scale > 1 {
+		return fmt.Errorf("--watermark-scale must be greater than 0 and at most 1")
+	}
+
+	w.value = image.Watermark{
+		Text:     w.text,
+		Font:     w.font,
+		Size:     w.size,
+		Color:    color,
+		Scale:    w.scale,
+		Opacity:  w.opacity,
+		Position: position,
+		Margin:   w.margin,
+	}
+	if w.image != "" {
+		data, err := os.ReadFile(w.image)
+		if err != nil {
+			return fmt.Errorf("error reading watermark image: %w", err)
+		}
+		if bimg.DetermineImageType(data) == bimg.UNKNOWN {
+			return fmt.Errorf("watermark image %s is not a supported image", w.image)
+		}
+		w.value.Image = data
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "4096", want: 4096},
+		{value: "512B", want: 512},
+		{value: "200KB", want: 200 << 10},
+		{value: "200kb", want: 200 << 10},
+		{value: "200k", want: 200 << 10},
+		{value: "64KiB", want: 64 << 10},
+		{value: "1.5MB", want: 3 << 19},
+		{value: "2 MB", want: 2 << 20},
+		{value: " 1GB ", want: 1 << 30},
+		{value: "1GiB", want: 1 << 30},
+		{value: "0", want: 0},
+		{value: "", wantErr: true},
+		{value: "MB", wantErr: true},
+		{value: "-1KB", wantErr: true},
+		{value: "10TB", wantErr: true},
+		{value: "1.5.2MB", wantErr: true},
+		{value: "1e30GB", wantErr: true},
+		{value: "NaN", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseByteSize(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseByteSize(%q) = %d, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseByteSize(%q) error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/image"
+)
+
+// inputFile is a local file selected for processing
+type inputFile struct {
+	// Path is the path of the file on disk
+	Path string
+	// Rel is the slash-separated path relative to the input it was found under
+	Rel string
+}
+
+// expandInputs resolves files, directories and glob patterns into a list of image files.
+// Directories are walked recursively and glob patterns support "**" to match any depth.
+func expandInputs(inputs []string) ([]inputFile, error) {
+	var files []inputFile
+	seen := make(map[string]bool)
+
+	add := func(path, rel string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		files = append(files, inputFile{Path: path, Rel: filepath.ToSlash(rel)})
+	}
+
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		switch {
+		case err == nil && info.IsDir():
+			// Walk the directory and keep paths relative to it
+			err := filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() || !image.IsImageFile(path) {
+					return nil
+				}
+				rel, err := filepath.Rel(input, path)
+				if err != nil {
+					return err
+				}
+				add(path, rel)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error reading directory %s: %w", input, err)
+			}
+
+		case err == nil:
+			// Plain file, always included even without an image extension
+			add(input, filepath.Base(input))
+
+		case hasGlobMeta(input):
+			pattern := filepath.ToSlash(input)
+			if !doublestar.ValidatePattern(pattern) {
+				return nil, usageErrorf("invalid glob pattern: %s", input)
+			}
+
+			matches, err := doublestar.FilepathGlob(input, doublestar.WithFilesOnly())
+			if err != nil {
+				return nil, fmt.Errorf("error matching %s: %w", input, err)
+			}
+			if len(matches) == 0 {
+				return nil, errs.Wrap(errs.ErrNotFound, fmt.Errorf("no files match pattern: %s", input))
+			}
+			sort.Strings(matches)
+
+			// Keep paths relative to the static part of the pattern
+			base, _ := doublestar.SplitPattern(pattern)
+			for _, match := range matches {
+				if !image.IsImageFile(match) {
+					continue
+				}
+				rel, err := filepath.Rel(filepath.FromSlash(base), match)
+				if err != nil {
+					return nil, err
+				}
+				add(match, rel)
+			}
+
+		default:
+			return nil, errs.Wrap(errs.ErrNotFound, fmt.Errorf("input file does not exist: %s", input))
+		}
+	}
+
+	return files, nil
+}
+
+// hasGlobMeta reports whether path contains glob metacharacters
+func hasGlobMeta(path string) bool {
+	for _, c := range path {
+		switch c {
+		case '*', '?', '[', '{':
+			return true
+		}
+	}
+	return false
+}
@@ -2,107 +2,186 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
 	"github.com/mingeme/imgood/internal/s3"
+	"github.com/mingeme/imgood/internal/storage"
 )
 
 var (
-	listPrefix    string
-	listLimit     int32
-	listSortBy    string
+	listPrefix     string
+	listLimit      int
+	listAll        bool
+	listSortBy     string
 	listDescending bool
 	listShowURLs   bool
+	listPresign    time.Duration
 )
 
 var listCmd = &cobra.Command{
 	Use:     "ls",
 	Aliases: []string{"list"},
-	Short:   "List objects in S3 bucket with filtering and sorting options",
-	Long: `List objects in S3 bucket with filtering and sorting options.
-	
+	Short:   "List objects in storage with filtering and sorting options",
+	Long: `List objects in storage with filtering and sorting options.
+
+Listing follows pagination until --limit objects were found. Use --all to
+inventory the whole bucket. Results sorted by name are printed as they
+arrive; other sort orders are applied once the listing is complete.
+
 Example:
-  imgood ls -p images/ -l 50 -s size -d -u`,
-	Run: func(cmd *cobra.Command, args []string) {
-		// Get S3 configuration and create client
-		s3Config := config.GetS3Config()
-		s3Client, err := s3.NewClient(s3Config)
+  imgood ls -p images/ -l 50 -s size -d -u
+  imgood ls --all -p images/
+  imgood ls -p drafts/ --presign 24h`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if listPresign != 0 {
+			if err := s3.CheckPresignExpiry(listPresign); err != nil {
+				return errs.Wrap(errUsage, err)
+			}
+		}
+
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
 		if err != nil {
-			fmt.Printf("Error: %s\n", err)
-			fmt.Println("Check your S3 configuration in config.toml or environment variables")
-			os.Exit(1)
+			return err
+		}
+
+		// Presigned URLs replace the public URLs, which are useless for a private bucket
+		if listPresign > 0 {
+			if _, err := storagePresigner(store); err != nil {
+				return err
+			}
+			listShowURLs = true
 		}
 
-		// List objects from S3
-		fmt.Printf("Listing objects in bucket '%s'", s3Config.Bucket)
+		// List objects from storage
+		w := humanOutput()
+		fmt.Fprintf(w, "Listing objects in %s", storageLocation())
 		if listPrefix != "" {
-			fmt.Printf(" with prefix '%s'", listPrefix)
+			fmt.Fprintf(w, " with prefix '%s'", listPrefix)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 
-		objects, err := s3Client.ListObjects(listPrefix, listLimit)
+		limit := listLimit
+		if listAll {
+			limit = 0
+		}
+
+		// Objects arrive in key order, so print them as they are listed
+		// unless a different order was requested
+		sortBy := strings.ToLower(listSortBy)
+		stream := sortBy != "size" && sortBy != "date" && !listDescending
+
+		// Structured output replaces the table with one record per object
+		count := 0
+		records := newRecordWriter()
+		printObject := func(obj storage.Object) error {
+			if structuredOutput() {
+				return records.write(listRecord(obj))
+			}
+			if count == 0 {
+				printListHeader()
+			}
+			printListObject(obj)
+			return nil
+		}
+
+		var objects []storage.Object
+		err = store.List(cmd.Context(), listPrefix, limit, func(obj storage.Object) error {
+			if listPresign > 0 {
+				presigned, err := objectURL(cmd.Context(), store, obj.Key, listPresign)
+				if err != nil {
+					return err
+				}
+				obj.URL = presigned
+			}
+			if !stream {
+				objects = append(objects, obj)
+				return nil
+			}
+			if err := printObject(obj); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
 		if err != nil {
-			fmt.Printf("Error listing objects: %s\n", err)
-			os.Exit(1)
+			return err
 		}
 
-		// Sort objects
-		sortObjects(objects, listSortBy, listDescending)
+		if !stream {
+			// Sort objects
+			sortObjects(objects, listSortBy, listDescending)
+
+			for _, obj := range objects {
+				if err := printObject(obj); err != nil {
+					return fmt.Errorf("error writing output: %w", err)
+				}
+				count++
+			}
+		}
 
 		// Display results
-		if len(objects) == 0 {
-			fmt.Println("No objects found.")
-			return
+		if count == 0 {
+			fmt.Fprintln(w, "No objects found.")
+			return nil
 		}
 
-		// Print header
-		fmt.Printf("%-40s %-15s %-20s", "KEY", "SIZE", "LAST MODIFIED")
-		if listShowURLs {
-			fmt.Printf(" %-60s", "URL")
+		fmt.Fprintf(w, "\nTotal: %d objects\n", count)
+		if limit > 0 && count == limit {
+			fmt.Fprintf(w, "Stopped at the limit of %d objects, use --all to list everything\n", limit)
 		}
-		fmt.Println()
-		fmt.Println(strings.Repeat("-", 80))
-
-		// Print objects
-		for _, obj := range objects {
-			// Format the key for display (truncate if too long)
-			displayKey := obj.Key
-			if len(displayKey) > 38 {
-				displayKey = "..." + displayKey[len(displayKey)-35:]
-			}
+		return nil
+	},
+}
 
-			// Format size
-			sizeStr := formatBytes(obj.Size)
+// printListHeader prints the column headers for the object listing
+func printListHeader() {
+	fmt.Printf("%-40s %-15s %-20s", "KEY", "SIZE", "LAST MODIFIED")
+	if listShowURLs {
+		fmt.Printf(" %-60s", "URL")
+	}
+	fmt.Println()
+	fmt.Println(strings.Repeat("-", 80))
+}
 
-			// Format date
-			dateStr := obj.LastModified.Format("2006-01-02 15:04:05")
+// printListObject prints one row of the object listing
+func printListObject(obj storage.Object) {
+	// Format the key for display (truncate if too long)
+	displayKey := obj.Key
+	if len(displayKey) > 38 {
+		displayKey = "..." + displayKey[len(displayKey)-35:]
+	}
 
-			// Print the object info
-			fmt.Printf("%-40s %-15s %-20s", displayKey, sizeStr, dateStr)
-			if listShowURLs {
-				fmt.Printf(" %s", obj.URL)
-			}
-			fmt.Println()
-		}
+	// Format size
+	sizeStr := formatBytes(obj.Size)
 
-		fmt.Printf("\nTotal: %d objects\n", len(objects))
-	},
+	// Format date
+	dateStr := obj.LastModified.Format("2006-01-02 15:04:05")
+
+	// Print the object info
+	fmt.Printf("%-40s %-15s %-20s", displayKey, sizeStr, dateStr)
+	if listShowURLs {
+		fmt.Printf(" %s", obj.URL)
+	}
+	fmt.Println()
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
 
 	// Define command line flags for listing
-	listCmd.Flags().StringVarP(&listPrefix, "prefix", "p", "", "Prefix filter for S3 objects")
-	listCmd.Flags().Int32VarP(&listLimit, "limit", "l", 100, "Maximum number of objects to list")
+	listCmd.Flags().StringVarP(&listPrefix, "prefix", "p", "", "Prefix filter for objects")
+	listCmd.Flags().IntVarP(&listLimit, "limit", "l", 100, "Maximum number of objects to list across all pages (0 for no limit)")
+	listCmd.Flags().BoolVarP(&listAll, "all", "a", false, "List all objects, ignoring --limit")
 	listCmd.Flags().StringVarP(&listSortBy, "sort", "s", "name", "Sort by: name, size, date")
 	listCmd.Flags().BoolVarP(&listDescending, "desc", "d", false, "Sort in descending order")
 	listCmd.Flags().BoolVarP(&listShowURLs, "urls", "u", false, "Show full URLs")
+	listCmd.Flags().DurationVar(&listPresign, "presign", 0, "Show presigned download URLs valid for this duration (e.g., 1h), implies --urls")
 
 	// Add shell completion for sort flag
 	_ = listCmd.RegisterFlagCompletionFunc("sort", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -124,7 +203,7 @@ func formatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-func sortObjects(objects []s3.S3Object, sortBy string, descending bool) {
+func sortObjects(objects []storage.Object, sortBy string, descending bool) {
 	switch strings.ToLower(sortBy) {
 	case "size":
 		if descending {
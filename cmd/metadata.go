@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/image"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+// addMetadataFlag registers the --meta flag on cmd
+func addMetadataFlag(cmd *cobra.Command, meta *[]string) {
+	cmd.Flags().StringArrayVar(meta, "meta", nil, "Custom metadata stored with the object as key=value (repeatable)")
+}
+
+// parseMetadata parses key=value pairs given with --meta
+func parseMetadata(pairs []string) (map[string]string, error) {
+	metadata := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid metadata: %s (expected key=value)", pair)
+		}
+		for _, r := range key {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+				return nil, fmt.Errorf("invalid metadata key: %s (use letters, digits, '-' and '_')", key)
+			}
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+// sourceOptions returns the metadata shared by every object created from one source:
+// the custom metadata, the original file name and the SHA-256 hash of the source
+func sourceOptions(name string, source []byte, metadata map[string]string) storage.PutOptions {
+	sum := sha256.Sum256(source)
+
+	opts := storage.PutOptions{Metadata: make(map[string]string, len(metadata)+2)}
+	// Metadata must be ASCII, so non-ASCII names are stored RFC 2047 encoded
+	opts.Metadata["original-filename"] = mime.QEncoding.Encode("utf-8", name)
+	opts.Metadata["source-sha256"] = hex.EncodeToString(sum[:])
+
+	// Custom metadata may override the generated values
+	for key, value := range metadata {
+		opts.Metadata[key] = value
+	}
+	return opts
+}
+
+// imageOptions completes opts for the encoded image data stored under key with its
+// content type, the cache control configured for key and its dimensions
+func imageOptions(opts storage.PutOptions, key string, data []byte) storage.PutOptions {
+	metadata := make(map[string]string, len(opts.Metadata)+2)
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+
+	if size, err := bimg.Size(data); err == nil {
+		metadata["width"] = strconv.Itoa(size.Width)
+		metadata["height"] = strconv.Itoa(size.Height)
+	}
+
+	opts.ContentType = image.MimeType(bimg.DetermineImageType(data))
+	opts.CacheControl = config.GetCacheControl(key)
+	opts.Metadata = metadata
+	return opts
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/pool"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+var (
+	moveOverwrite bool
+	moveDryRun    bool
+	moveJobs      int
+)
+
+// movePair is a single source to target move
+type movePair struct {
+	source string
+	target string
+}
+
+var moveCmd = &cobra.Command{
+	Use:     "mv <source> <target>",
+	Aliases: []string{"move", "rename"},
+	Short:   "Move or rename objects in storage",
+	Long: `Move or rename objects in storage.
+
+Objects are copied server-side without downloading them, then the source is
+deleted. A source ending in "/" moves every object below that prefix, which
+renames a "folder". A target ending in "/" keeps the source file name.
+
+Example:
+  imgood mv images/a.jpg images/b.jpg
+  imgood mv images/a.jpg archive/
+  imgood mv drafts/2026/ published/2026/`,
+	Args: usageArgs(cobra.ExactArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, target := args[0], args[1]
+
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		// Resolve the objects to move
+		pairs, err := collectMovePairs(cmd.Context(), store, source, target)
+		if err != nil {
+			return err
+		}
+
+		w := humanOutput()
+		records := newRecordWriter()
+		if len(pairs) == 0 {
+			fmt.Fprintln(w, "No objects found.")
+			return nil
+		}
+
+		// Structured output replaces the listing with one record per move
+		if moveDryRun {
+			planned := make([]outputRecord, 0, len(pairs))
+			for _, pair := range pairs {
+				planned = append(planned, outputRecord{Key: pair.target, Source: pair.source, Status: statusPending})
+				if !structuredOutput() {
+					fmt.Printf("would move: %s -> %s\n", pair.source, pair.target)
+				}
+			}
+			records.writeAll(planned)
+			fmt.Fprintf(w, "\n%d objects would be moved\n", len(pairs))
+			return nil
+		}
+
+		// Move objects concurrently, collecting failures instead of stopping the batch
+		summary := batchSummary{verb: "Moved", noun: "objects", total: len(pairs)}
+		pool.Run(cmd.Context(), len(pairs), moveJobs,
+			func(ctx context.Context, i int) jobResult {
+				var out bytes.Buffer
+				fileURL, err := moveObject(ctx, store, pairs[i].source, pairs[i].target, &out)
+				result := jobResult{output: out.String(), url: fileURL, err: err}
+				if err == nil {
+					record := objectRecord(ctx, store, pairs[i].target, fileURL, statusMoved)
+					record.Source = pairs[i].source
+					result.records = []outputRecord{record}
+				}
+				return result
+			},
+			func(i int, result jobResult) {
+				if len(pairs) > 1 {
+					fmt.Fprintf(w, "[%d/%d] %s\n", i+1, len(pairs), pairs[i].source)
+				}
+				fmt.Fprint(w, result.output)
+				records.writeAll(result.records)
+				summary.add(pairs[i].source, result.err)
+				if result.err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", result.err)
+					return
+				}
+				fmt.Fprintf(w, "Successfully moved to: %s\n", result.url)
+			})
+
+		if len(pairs) > 1 || cmd.Context().Err() != nil {
+			summary.print()
+		}
+		return summary.err()
+	},
+}
+
+// collectMovePairs resolves a source key or prefix into source and target key pairs
+func collectMovePairs(ctx context.Context, store storage.Storage, source, target string) ([]movePair, error) {
+	if source == "" || target == "" {
+		return nil, usageErrorf("source and target cannot be empty")
+	}
+
+	// Single object
+	if !strings.HasSuffix(source, "/") {
+		if strings.HasSuffix(target, "/") {
+			target += path.Base(source)
+		}
+		return []movePair{{source: source, target: target}}, nil
+	}
+
+	// Prefix move, keep the part of each key below the source prefix
+	if !strings.HasSuffix(target, "/") {
+		target += "/"
+	}
+	if strings.HasPrefix(target, source) {
+		return nil, usageErrorf("target prefix %s cannot be inside source prefix %s", target, source)
+	}
+
+	var pairs []movePair
+	err := store.List(ctx, source, 0, func(obj storage.Object) error {
+		pairs = append(pairs, movePair{
+			source: obj.Key,
+			target: target + strings.TrimPrefix(obj.Key, source),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// moveObject copies sourceKey to targetKey server-side and deletes the source, returning the target URL
+func moveObject(ctx context.Context, store storage.Storage, sourceKey, targetKey string, out io.Writer) (string, error) {
+	if sourceKey == targetKey {
+		return "", usageErrorf("source and target keys cannot be the same")
+	}
+
+	// Check if target already exists
+	exists, err := storage.Exists(ctx, store, targetKey)
+	if err != nil {
+		return "", fmt.Errorf("error checking target object: %w", err)
+	}
+	if exists && !moveOverwrite {
+		return "", errs.Wrap(errs.ErrConflict, fmt.Errorf("target object already exists: %s (use --overwrite flag to overwrite existing objects)", targetKey))
+	}
+	if exists {
+		fmt.Fprintf(out, "Warning: Overwriting existing object: %s\n", targetKey)
+	}
+
+	fmt.Fprintf(out, "Moving %s -> %s\n", sourceKey, targetKey)
+	if err := storage.Copy(ctx, store, sourceKey, targetKey); err != nil {
+		return "", err
+	}
+
+	// Only delete the source once the copy succeeded
+	if err := store.Delete(ctx, sourceKey); err != nil {
+		return "", fmt.Errorf("copied to %s but failed to delete source: %w", targetKey, err)
+	}
+
+	return store.URL(targetKey), nil
+}
+
+func init() {
+	rootCmd.AddCommand(moveCmd)
+
+	// Define command line flags for move operation
+	moveCmd.Flags().BoolVar(&moveOverwrite, "overwrite", false, "Overwrite target objects if they already exist")
+	moveCmd.Flags().BoolVarP(&moveDryRun, "dry-run", "n", false, "List the moves without performing them")
+	moveCmd.Flags().IntVarP(&moveJobs, "jobs", "j", defaultJobs, "Number of objects to move concurrently")
+}
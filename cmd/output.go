@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2non/bimg"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+// Formats for --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+	outputCSV  = "csv"
+)
+
+// Record statuses
+const (
+	statusUploaded   = "uploaded"
+	statusCopied     = "copied"
+	statusSkipped    = "skipped"
+	statusMoved      = "moved"
+	statusDeleted    = "deleted"
+	statusDownloaded = "downloaded"
+	statusAborted    = "aborted"
+	// statusPending marks objects listed by a dry run, or by rm without --yes
+	statusPending = "pending"
+	// statusIncomplete marks multipart uploads listed by uploads
+	statusIncomplete = "incomplete"
+)
+
+var outputFormat string
+
+// outputRecord describes one object in structured output. Fields that are unknown,
+// like the dimensions of an object copied server-side, are left empty.
+type outputRecord struct {
+	Key          string           `json:"key" yaml:"key"`
+	Source       string           `json:"source,omitempty" yaml:"source,omitempty"`
+	URL          string           `json:"url,omitempty" yaml:"url,omitempty"`
+	Path         string           `json:"path,omitempty" yaml:"path,omitempty"`
+	Size         *int64           `json:"size,omitempty" yaml:"size,omitempty"`
+	Width        int              `json:"width,omitempty" yaml:"width,omitempty"`
+	Height       int              `json:"height,omitempty" yaml:"height,omitempty"`
+	Format       string           `json:"format,omitempty" yaml:"format,omitempty"`
+	OriginalSize int64            `json:"original_size,omitempty" yaml:"original_size,omitempty"`
+	Ratio        float64          `json:"ratio,omitempty" yaml:"ratio,omitempty"`
+	Quality      int              `json:"quality,omitempty" yaml:"quality,omitempty"`
+	SSIM         float64          `json:"ssim,omitempty" yaml:"ssim,omitempty"`
+	Candidates   map[string]int64 `json:"candidates,omitempty" yaml:"candidates,omitempty"`
+	ETag         string           `json:"etag,omitempty" yaml:"etag,omitempty"`
+	LastModified string           `json:"last_modified,omitempty" yaml:"last_modified,omitempty"`
+	Method       string           `json:"method,omitempty" yaml:"method,omitempty"`
+	Expires      string           `json:"expires,omitempty" yaml:"expires,omitempty"`
+	UploadID     string           `json:"upload_id,omitempty" yaml:"upload_id,omitempty"`
+	Initiated    string           `json:"initiated,omitempty" yaml:"initiated,omitempty"`
+	Status       string           `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// csvHeader lists the CSV columns in the order written by csvRow
+var csvHeader = []string{"key", "source", "url", "path", "size", "width", "height", "format", "original_size", "ratio", "quality", "ssim", "candidates", "etag", "last_modified", "method", "expires", "upload_id", "initiated", "status"}
+
+// csvRow returns the CSV columns of a record, leaving unknown values empty
+func (r outputRecord) csvRow() []string {
+	optional := func(n int64) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	// Candidates are written as format=size pairs, smallest first
+	names := slices.Collect(maps.Keys(r.Candidates))
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(r.Candidates[a], r.Candidates[b]), cmp.Compare(a, b))
+	})
+	candidates := make([]string, len(names))
+	for i, name := range names {
+		candidates[i] = name + "=" + strconv.FormatInt(r.Candidates[name], 10)
+	}
+	decimal := func(f float64) string {
+		if f == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	size := ""
+	if r.Size != nil {
+		size = strconv.FormatInt(*r.Size, 10)
+	}
+	return []string{r.Key, r.Source, r.URL, r.Path, size, optional(int64(r.Width)), optional(int64(r.Height)),
+		r.Format, optional(r.OriginalSize), decimal(r.Ratio), optional(int64(r.Quality)), decimal(r.SSIM), strings.Join(candidates, " "),
+		r.ETag, r.LastModified, r.Method, r.Expires, r.UploadID, r.Initiated, r.Status}
+}
+
+// validateOutputFormat checks the --output flag
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML, outputCSV:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s (expected text, json, yaml or csv)", outputFormat)
+	}
+}
+
+// structuredOutput reports whether stdout carries records instead of text
+func structuredOutput() bool {
+	return outputFormat != outputText
+}
+
+// humanOutput returns where human-readable progress and results are printed:
+// stdout by default, stderr when stdout carries records
+func humanOutput() io.Writer {
+	if structuredOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// recordWriter writes records to stdout in the --output format
+type recordWriter struct {
+	csv    *csv.Writer
+	header bool
+}
+
+// newRecordWriter creates a writer for the --output format
+func newRecordWriter() *recordWriter {
+	rw := &recordWriter{}
+	if outputFormat == outputCSV {
+		rw.csv = csv.NewWriter(os.Stdout)
+	}
+	return rw
+}
+
+// write prints a record, doing nothing for text output. Records are written as
+// JSON lines, YAML sequence items or CSV rows so they can be streamed.
+func (rw *recordWriter) write(record outputRecord) error {
+	switch outputFormat {
+	case outputJSON:
+		return json.NewEncoder(os.Stdout).Encode(record)
+	case outputYAML:
+		data, err := yaml.Marshal([]outputRecord{record})
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	case outputCSV:
+		if !rw.header {
+			rw.header = true
+			if err := rw.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		if err := rw.csv.Write(record.csvRow()); err != nil {
+			return err
+		}
+		rw.csv.Flush()
+		return rw.csv.Error()
+	default:
+		return nil
+	}
+}
+
+// writeAll prints records, reporting write errors on stderr
+func (rw *recordWriter) writeAll(records []outputRecord) {
+	for _, record := range records {
+		if err := rw.write(record); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %s\n", err)
+		}
+	}
+}
+
+// imageRecord describes encoded image data stored under key, created from a source
+// of originalSize bytes. The ETag is only fetched when records are printed.
+func imageRecord(ctx context.Context, store storage.Storage, key, url string, data []byte, originalSize int64, status string) outputRecord {
+	record := encodedRecord(key, data, originalSize, status)
+	record.URL = url
+	if structuredOutput() {
+		if obj, err := store.Head(ctx, key); err == nil {
+			record.ETag = obj.ETag
+		}
+	}
+	return record
+}
+
+// encodedRecord describes encoded image data created from a source of originalSize bytes
+func encodedRecord(key string, data []byte, originalSize int64, status string) outputRecord {
+	record := outputRecord{
+		Key:          key,
+		Size:         recordSize(int64(len(data))),
+		Format:       bimg.ImageTypeName(bimg.DetermineImageType(data)),
+		OriginalSize: originalSize,
+		Status:       status,
+	}
+	if size, err := bimg.Size(data); err == nil {
+		record.Width, record.Height = size.Width, size.Height
+	}
+	if originalSize > 0 {
+		record.Ratio = math.Round(float64(len(data))/float64(originalSize)*10000) / 10000
+	}
+	return record
+}
+
+// objectRecord describes an object whose content was not transferred, like a server-side
+// copy or an existing object that was skipped, from its stored information
+func objectRecord(ctx context.Context, store storage.Storage, key, url, status string) outputRecord {
+	record := outputRecord{Key: key, URL: url, Status: status}
+	if !structuredOutput() {
+		return record
+	}
+	if obj, err := store.Head(ctx, key); err == nil {
+		record.Size = recordSize(obj.Size)
+		record.ETag = obj.ETag
+		record.LastModified = formatRecordTime(obj.LastModified)
+	}
+	return record
+}
+
+// listRecord describes an object found by ls
+func listRecord(obj storage.Object) outputRecord {
+	return outputRecord{
+		Key:          obj.Key,
+		URL:          obj.URL,
+		Size:         recordSize(obj.Size),
+		ETag:         obj.ETag,
+		LastModified: formatRecordTime(obj.LastModified),
+	}
+}
+
+// recordSize returns a known size for a record, which may be 0 for empty objects
+func recordSize(n int64) *int64 {
+	return &n
+}
+
+// formatRecordTime formats a modification time as RFC 3339, empty if unknown
+func formatRecordTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
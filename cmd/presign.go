@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/s3"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+// defaultPresignExpiry is how long presigned URLs are valid unless --expires is given
+const defaultPresignExpiry = 15 * time.Minute
+
+var (
+	presignExpires     time.Duration
+	presignPut         bool
+	presignContentType string
+)
+
+var presignCmd = &cobra.Command{
+	Use:   "presign <keys...>",
+	Short: "Create time-limited URLs for objects in a private bucket",
+	Long: `Create presigned URLs that grant access to single objects without credentials
+until they expire.
+
+By default the URLs download the object (GET). With --put they let someone
+without credentials upload an object under the key, e.g. with curl -T.
+
+Example:
+  imgood presign drafts/hero.webp
+  imgood presign -e 24h drafts/hero.webp drafts/thumb.webp
+  imgood presign --put --content-type image/webp uploads/from-alice.webp`,
+	Args: usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if presignContentType != "" && !presignPut {
+			return usageErrorf("--content-type can only be used with --put")
+		}
+		if err := s3.CheckPresignExpiry(presignExpires); err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		presigner, err := storagePresigner(store)
+		if err != nil {
+			return err
+		}
+
+		records := newRecordWriter()
+		method, expires := http.MethodGet, formatRecordTime(time.Now().Add(presignExpires))
+		if presignPut {
+			method = http.MethodPut
+		}
+		for _, key := range args {
+			var presigned string
+			if presignPut {
+				presigned, err = presigner.PresignPut(cmd.Context(), key, presignExpires, presignContentType)
+			} else {
+				presigned, err = presigner.PresignGet(cmd.Context(), key, presignExpires)
+			}
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case structuredOutput():
+				if err := records.write(outputRecord{Key: key, URL: presigned, Method: method, Expires: expires}); err != nil {
+					return fmt.Errorf("error writing output: %w", err)
+				}
+			case len(args) > 1:
+				fmt.Printf("%s: %s\n", key, presigned)
+			default:
+				fmt.Println(presigned)
+			}
+		}
+		return nil
+	},
+}
+
+// storagePresigner returns the presigning interface of a backend
+func storagePresigner(store storage.Storage) (storage.Presigner, error) {
+	presigner, ok := store.(storage.Presigner)
+	if !ok {
+		return nil, errs.Wrap(errs.ErrConfig, fmt.Errorf("presigned URLs are not supported by the %s backend", config.GetBackend()))
+	}
+	return presigner, nil
+}
+
+// objectURL returns the URL of an object, presigned for download when expires is set
+func objectURL(ctx context.Context, store storage.Storage, key string, expires time.Duration) (string, error) {
+	if expires == 0 {
+		return store.URL(key), nil
+	}
+
+	presigner, err := storagePresigner(store)
+	if err != nil {
+		return "", err
+	}
+	return presigner.PresignGet(ctx, key, expires)
+}
+
+func init() {
+	rootCmd.AddCommand(presignCmd)
+
+	// Define command line flags for presign operation
+	presignCmd.Flags().DurationVarP(&presignExpires, "expires", "e", defaultPresignExpiry, "How long the URLs are valid (e.g., 15m, 24h), at most 7 days")
+	presignCmd.Flags().BoolVar(&presignPut, "put", false, "Create URLs for uploading instead of downloading")
+	presignCmd.Flags().StringVar(&presignContentType, "content-type", "", "Content-Type the upload must use, only with --put")
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+var (
+	removePrefixes     []string
+	removeDryRun       bool
+	removeYes          bool
+	removeConfirmAbove int
+)
+
+var removeCmd = &cobra.Command{
+	Use:     "rm [keys or glob patterns...]",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Delete objects from storage",
+	Long: `Delete objects from storage by key, prefix or glob pattern.
+
+Glob patterns are matched against object keys ("**" matches any number of
+path segments). Without --yes the objects that would be deleted are only
+listed. --confirm-above allows deleting up to that many objects without --yes.
+
+Example:
+  imgood rm images/old.jpg images/older.jpg --yes
+  imgood rm 'drafts/**/*.png'
+  imgood rm -p tmp/ --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate required parameters
+		if len(args) == 0 && len(removePrefixes) == 0 {
+			return usageErrorf("at least one key, pattern or prefix is required")
+		}
+		for _, prefix := range removePrefixes {
+			if prefix == "" {
+				return usageErrorf("prefix cannot be empty")
+			}
+		}
+
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		// Resolve keys, patterns and prefixes into objects
+		objects, err := collectRemoveTargets(cmd.Context(), store, args, removePrefixes)
+		if err != nil {
+			return err
+		}
+
+		w := humanOutput()
+		records := newRecordWriter()
+		if len(objects) == 0 {
+			fmt.Fprintln(w, "No objects found.")
+			return nil
+		}
+
+		// Only list the objects unless deletion was confirmed. Structured output replaces
+		// the listing with one record per object.
+		needsConfirm := len(objects) > removeConfirmAbove && !removeYes
+		if removeDryRun || needsConfirm {
+			var total int64
+			planned := make([]outputRecord, 0, len(objects))
+			for _, obj := range objects {
+				planned = append(planned, removeRecord(obj, statusPending))
+				if !structuredOutput() {
+					fmt.Printf("would delete: %s (%s)\n", obj.Key, formatBytes(obj.Size))
+				}
+				total += obj.Size
+			}
+			records.writeAll(planned)
+			fmt.Fprintf(w, "\n%d objects (%s) would be deleted\n", len(objects), formatBytes(total))
+			if !removeDryRun {
+				fmt.Fprintln(os.Stderr, "Nothing was deleted, run again with --yes to delete these objects")
+			}
+			return nil
+		}
+
+		keys := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+
+		// Keys not attempted after an error are returned with that error, which is
+		// reported once below
+		failed, err := storage.DeleteAll(cmd.Context(), store, keys)
+		failedKeys := make(map[string]bool, len(failed))
+		for _, f := range failed {
+			failedKeys[f.Key] = true
+			if err == nil || !errors.Is(f.Err, err) {
+				fmt.Fprintf(os.Stderr, "Error deleting %s: %s\n", f.Key, f.Err)
+			}
+		}
+
+		// Objects deleted before an error are still reported
+		var deleted []outputRecord
+		for _, obj := range objects {
+			if !failedKeys[obj.Key] {
+				fmt.Fprintf(w, "deleted: %s\n", obj.Key)
+				deleted = append(deleted, removeRecord(obj, statusDeleted))
+			}
+		}
+		records.writeAll(deleted)
+		if err != nil {
+			fmt.Fprintf(w, "\nDeleted %d of %d objects before the error\n", len(keys)-len(failed), len(keys))
+			return err
+		}
+
+		fmt.Fprintf(w, "\nDeleted %d of %d objects, %d failed\n", len(keys)-len(failed), len(keys), len(failed))
+		if len(failed) > 0 {
+			failures := make([]error, 0, len(failed))
+			for _, f := range failed {
+				failures = append(failures, f.Err)
+			}
+			return &reportedError{err: errors.Join(failures...)}
+		}
+		return nil
+	},
+}
+
+// removeRecord describes an object listed or deleted by rm
+func removeRecord(obj storage.Object, status string) outputRecord {
+	record := listRecord(obj)
+	record.Status = status
+	return record
+}
+
+// collectRemoveTargets resolves exact keys, glob patterns and prefixes into a de-duplicated list of objects
+func collectRemoveTargets(ctx context.Context, store storage.Storage, keys, prefixes []string) ([]storage.Object, error) {
+	var objects []storage.Object
+	seen := make(map[string]bool)
+
+	add := func(obj storage.Object) error {
+		if !seen[obj.Key] {
+			seen[obj.Key] = true
+			objects = append(objects, obj)
+		}
+		return nil
+	}
+
+	for _, key := range keys {
+		if !hasGlobMeta(key) {
+			obj, err := store.Head(ctx, key)
+			if errors.Is(err, storage.ErrNotFound) {
+				fmt.Fprintf(os.Stderr, "Warning: Object does not exist: %s\n", key)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			add(obj)
+			continue
+		}
+
+		if !doublestar.ValidatePattern(key) {
+			return nil, usageErrorf("invalid glob pattern: %s", key)
+		}
+
+		// Only list below the static part of the pattern
+		base, _ := doublestar.SplitPattern(key)
+		prefix := ""
+		if base != "." {
+			prefix = base + "/"
+		}
+
+		err := store.List(ctx, prefix, 0, func(obj storage.Object) error {
+			if doublestar.MatchUnvalidated(key, obj.Key) {
+				return add(obj)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, prefix := range prefixes {
+		if err := store.List(ctx, prefix, 0, add); err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+
+	// Define command line flags for remove operation
+	removeCmd.Flags().StringArrayVarP(&removePrefixes, "prefix", "p", nil, "Delete all objects with this key prefix (repeatable)")
+	removeCmd.Flags().BoolVarP(&removeDryRun, "dry-run", "n", false, "List the objects that would be deleted without deleting them")
+	removeCmd.Flags().BoolVarP(&removeYes, "yes", "y", false, "Delete the objects instead of listing them")
+	removeCmd.Flags().IntVar(&removeConfirmAbove, "confirm-above", 0, "Delete up to this many objects without --yes")
+}
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
 )
 
 var rootCmd = &cobra.Command{
@@ -14,27 +20,81 @@ var rootCmd = &cobra.Command{
 	Short: "imgood - Image processing and S3 management tool",
 	Long: `imgood is a command-line tool for processing images and managing them in S3.
 It supports uploading, copying, and listing images with various processing options.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Args: usageArgs(cobra.NoArgs),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Apply the selected profile once flags are parsed
+		if err := config.UseProfile(viper.GetString("profile")); err != nil {
+			return err
+		}
+		return validateOutputFormat()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// If no subcommand is provided, show help
-		cmd.Help()
+		return cmd.Help()
 	},
+	// Errors are printed by Execute, which also chooses the exit code
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
-// Execute runs the root command
+// Execute runs the root command and exits with the code matching the error, see exitCode
 func Execute() {
 	// Initialize configuration
 	if err := config.Init(); err != nil {
-		fmt.Printf("Warning: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	// Cancel the command context on Ctrl-C so batches stop cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore default signal handling so a second Ctrl-C exits immediately
+		<-ctx.Done()
+		stop()
+	}()
+
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(exitInterrupted)
+	}
+
+	if err != nil {
+		var reported *reportedError
+		if !errors.As(err, &reported) {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+
+		code := exitCode(err)
+		switch code {
+		case exitUsage:
+			fmt.Fprintf(os.Stderr, "Run '%s --help' for usage\n", cmd.CommandPath())
+		case exitConfig:
+			fmt.Fprintln(os.Stderr, "Check your configuration in config.toml or environment variables, see 'imgood config show'")
+		}
+		os.Exit(code)
 	}
 }
 
 func init() {
-	// Add global flags here if needed
+	// Report invalid flags as usage errors
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return errs.Wrap(errUsage, err)
+	})
+
+	// Add global flags
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Timeout for each storage request (e.g., 30s, 2m), 0 for no timeout")
+	_ = config.BindFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format of object commands: text, json (one object per line), yaml or csv")
+	_ = rootCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{outputText, outputJSON, outputYAML, outputCSV}, cobra.ShellCompDirectiveNoFileComp
+	})
+	rootCmd.PersistentFlags().String("profile", "", "Configuration profile to use, defaults to default_profile from config.toml")
+	_ = config.BindFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return config.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
 
 	// Add completion command
 	rootCmd.AddCommand(completionCmd)
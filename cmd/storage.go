@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/s3"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+// newStorage creates the storage backend selected by the "backend" config key.
+// Errors are configuration errors.
+func newStorage(ctx context.Context) (storage.Storage, error) {
+	switch backend := config.GetBackend(); backend {
+	case config.BackendS3, "":
+		client, err := s3.NewClient(ctx, config.GetS3Config())
+		if err != nil {
+			return nil, errs.Wrap(errs.ErrConfig, err)
+		}
+		return client, nil
+	case config.BackendLocal:
+		local, err := storage.NewLocal(config.GetLocalConfig())
+		if err != nil {
+			return nil, errs.Wrap(errs.ErrConfig, err)
+		}
+		return local, nil
+	default:
+		return nil, errs.Wrap(errs.ErrConfig, fmt.Errorf("unsupported storage backend: %s (expected %s or %s)", backend, config.BackendS3, config.BackendLocal))
+	}
+}
+
+// storageLocation describes where the configured backend stores objects
+func storageLocation() string {
+	if config.GetBackend() == config.BackendLocal {
+		return fmt.Sprintf("directory '%s'", config.GetLocalConfig().Root)
+	}
+	return fmt.Sprintf("bucket '%s'", config.GetS3Config().Bucket)
+}
@@ -1,159 +1,451 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/h2non/bimg"
 	"github.com/spf13/cobra"
 
-	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
 	"github.com/mingeme/imgood/internal/image"
+	"github.com/mingeme/imgood/internal/pool"
 	"github.com/mingeme/imgood/internal/s3"
+	"github.com/mingeme/imgood/internal/storage"
 )
 
+// defaultDedupeKeyTemplate is the key template used by --dedupe when --key-template is not given
+const defaultDedupeKeyTemplate = "{hash}.{ext}"
+
+// Strategies for --on-conflict when an object already exists under the key
+const (
+	conflictFail      = "fail"
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictRename    = "rename"
+)
+
+// maxRenameAttempts limits the suffixes tried by --on-conflict=rename
+const maxRenameAttempts = 1000
+
 var (
-	uploadInputPath  string
-	uploadKey        string
-	uploadCompress   bool
-	uploadQuality    int
-	uploadResize     string
-	uploadTimestamp  bool
+	uploadInputPaths   []string
+	uploadKey          string
+	uploadPrefix       string
+	uploadCompress     bool
+	uploadFormat       string
+	uploadQuality      int
+	uploadResize       resizeFlags
+	uploadTimestamp    bool
 	uploadKeepMetadata bool
-	uploadNoRotate    bool
+	uploadNoRotate     bool
+	uploadJobs         int
+	uploadPresign      time.Duration
+	uploadMeta         []string
+	uploadKeyTemplate  string
+	uploadDedupe       bool
+	uploadOnConflict   string
+	uploadVariantFlags variantFlags
+	uploadTarget       targetFlags
+	uploadAutoFormats  string
+	uploadKeepOriginal bool
+	uploadWatermark    watermarkFlags
+
+	// uploadCandidates holds the formats tried by --format auto
+	uploadCandidates []bimg.ImageType
 )
 
 var uploadCmd = &cobra.Command{
-	Use:     "up",
+	Use:     "up [files, directories or globs...]",
 	Aliases: []string{"upload"},
-	Short:   "Upload an image to S3 with optional compression",
-	Long: `Upload an image to S3 with optional compression and resizing.
-	
+	Short:   "Upload images to storage with optional compression",
+	Long: `Upload images to storage with optional compression and resizing.
+
+Inputs can be files, directories (walked recursively) or glob patterns
+("**" matches any number of directories). Paths relative to the directory
+or to the static part of the pattern are preserved under --prefix.
+
 Example:
-  imgood up -i image.jpg -c -q 80 -r 800,600`,
-	Run: func(cmd *cobra.Command, args []string) {
+  imgood up -i image.jpg -c -q 80 -r 800x600
+  imgood up -i './shots/**/*.png' --prefix blog/2026/ -c
+  imgood up -i photo.jpg -f avif -q 60
+  imgood up -i hero.jpg -c --variants 320,640,1280,1920
+  imgood up -i draft.png -p drafts/ --presign 24h
+  imgood up -i photo.jpg -c --meta author=alice --meta license=cc-by
+  imgood up -i shot.png -c --key-template 'shots/{date:2006/01}/{hash:12}.{ext}' --dedupe`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputs := append(append([]string(nil), uploadInputPaths...), args...)
+
 		// Validate required parameters
-		if uploadInputPath == "" {
-			fmt.Println("Error: Input path is required")
-			cmd.Help()
-			os.Exit(1)
+		if len(inputs) == 0 {
+			return usageErrorf("input path is required")
 		}
-
-		// Get S3 configuration
-		s3Config := config.GetS3Config()
-
-		// Check if input file exists
-		if _, err := os.Stat(uploadInputPath); os.IsNotExist(err) {
-			fmt.Printf("Error: Input file does not exist: %s\n", uploadInputPath)
-			os.Exit(1)
+		if err := uploadResize.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+		if err := uploadTarget.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+		if err := uploadWatermark.validate(cmd); err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+		if uploadTarget.enabled() && uploadVariantFlags.enabled() {
+			return usageErrorf("--max-bytes and --target-ssim cannot be combined with --variants")
+		}
+		targetFormat, err := uploadTargetFormat()
+		if err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+		if strings.EqualFold(uploadFormat, image.AutoFormat) {
+			if uploadVariantFlags.enabled() {
+				return usageErrorf("--format auto cannot be combined with --variants")
+			}
+			if uploadKeepOriginal && (uploadResize.enabled() || uploadWatermark.enabled()) {
+				return usageErrorf("--keep-original cannot be combined with --resize or a watermark")
+			}
+			if uploadCandidates, err = autoFormats(uploadAutoFormats); err != nil {
+				return errs.Wrap(errUsage, err)
+			}
+		} else if uploadKeepOriginal || uploadAutoFormats != "" {
+			return usageErrorf("--keep-original and --auto-formats require --format auto")
 		}
 
-		// Create image processor
-		processor, err := image.NewProcessor(uploadInputPath)
+		// Resolve directories and glob patterns into files
+		files, err := expandInputs(inputs)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return errs.Wrap(errs.ErrNotFound, fmt.Errorf("no image files found in input"))
+		}
+		if err := uploadVariantFlags.validate(); err != nil {
+			return errs.Wrap(errUsage, err)
+		}
+		metadata, err := parseMetadata(uploadMeta)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-
-		// Get original image info
-		width0, height0, size, format := processor.GetOriginalInfo()
-		fmt.Printf("Original image: %dx%d, %d bytes, format: %s\n", width0, height0, size, format)
-
-		// Process the image if compression is requested or if we need to handle EXIF orientation/metadata
-		var imageData []byte
-		if uploadCompress || !uploadKeepMetadata || !uploadNoRotate {
-			// Process the image
-			processOpts := image.ProcessOptions{
-				Quality:      uploadQuality,
-				Width:        0,
-				Height:       0,
-				Format:       bimg.WEBP, // Convert to WebP format for better compression
-				KeepMetadata: uploadKeepMetadata,
-				NoRotate:     uploadNoRotate,
+			return errs.Wrap(errUsage, err)
+		}
+		if uploadPresign != 0 {
+			if err := s3.CheckPresignExpiry(uploadPresign); err != nil {
+				return errs.Wrap(errUsage, err)
 			}
-			
-			// If not compressing but still processing for orientation/metadata, keep original format
-			if !uploadCompress {
-				imageType := bimg.DetermineImageType(processor.GetOriginalBuffer())
-				processOpts.Format = imageType
+		}
+		if uploadPresign > 0 && uploadVariantFlags.enabled() {
+			return usageErrorf("--presign cannot be combined with --variants")
+		}
+		if uploadDedupe && uploadKeyTemplate == "" {
+			// Deduplication needs content-addressed keys
+			uploadKeyTemplate = defaultDedupeKeyTemplate
+		}
+		if uploadKeyTemplate != "" {
+			if err := image.ValidateKeyTemplate(uploadKeyTemplate); err != nil {
+				return errs.Wrap(errUsage, err)
 			}
-			
-			// Parse resize parameter if provided
-			if uploadResize != "" {
-				dimensions := strings.Split(uploadResize, ",")
-				if len(dimensions) == 2 {
-					width, err := strconv.Atoi(strings.TrimSpace(dimensions[0]))
-					if err == nil && width > 0 {
-						processOpts.Width = width
-					}
-					
-					height, err := strconv.Atoi(strings.TrimSpace(dimensions[1]))
-					if err == nil && height > 0 {
-						processOpts.Height = height
-					}
-				}
+		}
+		if uploadDedupe && !strings.Contains(uploadKeyTemplate, "{hash") {
+			return usageErrorf("--dedupe requires {hash} in --key-template")
+		}
+		switch uploadOnConflict {
+		case conflictFail, conflictOverwrite:
+		case conflictSkip, conflictRename:
+			if uploadVariantFlags.enabled() {
+				return usageErrorf("--on-conflict=%s cannot be combined with --variants", uploadOnConflict)
 			}
+		default:
+			return usageErrorf("unsupported conflict strategy: %s (expected fail, overwrite, skip or rename)", uploadOnConflict)
+		}
+		if uploadDedupe && (uploadKey != "" || uploadVariantFlags.enabled()) {
+			return usageErrorf("--dedupe cannot be combined with --key or --variants")
+		}
+		if uploadKey != "" && len(files) > 1 {
+			return usageErrorf("--key can only be used with a single input file, use --prefix instead")
+		}
 
-			newImage, err := processor.Process(processOpts)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if uploadPresign > 0 {
+			if _, err := storagePresigner(store); err != nil {
+				return err
 			}
+		}
+
+		// Process and upload files concurrently, collecting failures instead of stopping the batch
+		w := humanOutput()
+		records := newRecordWriter()
+		summary := batchSummary{verb: "Uploaded", noun: "files", total: len(files)}
+		pool.Run(cmd.Context(), len(files), uploadJobs,
+			func(ctx context.Context, i int) jobResult {
+				var out bytes.Buffer
+				fileRecords, skipped, err := uploadFile(ctx, store, files[i], targetFormat, metadata, &out)
+				result := jobResult{output: out.String(), skipped: skipped, err: err, records: fileRecords}
+				if len(fileRecords) > 0 {
+					// Variants are ordered by width, so the last one is the largest
+					result.url = fileRecords[len(fileRecords)-1].URL
+				}
+				return result
+			},
+			func(i int, result jobResult) {
+				if len(files) > 1 {
+					fmt.Fprintf(w, "[%d/%d] %s\n", i+1, len(files), files[i].Path)
+				}
+				fmt.Fprint(w, result.output)
+				records.writeAll(result.records)
+				if result.skipped {
+					summary.skip()
+					fmt.Fprintf(w, "Object already exists, skipped: %s\n", result.url)
+					return
+				}
+				summary.add(files[i].Path, result.err)
+				if result.err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", result.err)
+					return
+				}
+				fmt.Fprintf(w, "Successfully uploaded: %s\n", result.url)
+			})
 
-			imageData = newImage
-			fmt.Printf("Compressed image: %d bytes (%.2f%% of original)\n",
-				len(newImage), float64(len(newImage))/float64(size)*100)
-		} else {
-			// Use original image
-			imageData = processor.GetOriginalBuffer()
+		if len(files) > 1 || cmd.Context().Err() != nil {
+			summary.print()
 		}
+		return summary.err()
+	},
+}
+
+// uploadTargetFormat returns the format images are converted to, or bimg.UNKNOWN to keep
+// the original format or choose it with --format auto. --compress without --format
+// converts to WebP.
+func uploadTargetFormat() (bimg.ImageType, error) {
+	if strings.EqualFold(uploadFormat, image.AutoFormat) {
+		return bimg.UNKNOWN, nil
+	}
+	if uploadFormat != "" {
+		return image.ParseFormat(uploadFormat)
+	}
+	if uploadCompress {
+		return bimg.WEBP, nil
+	}
+	return bimg.UNKNOWN, nil
+}
+
+// uploadObjectKey builds the object key for an input file from --key, or from --prefix, its
+// relative path and either --key-template applied to the stored data or the file name
+func uploadObjectKey(file inputFile, format bimg.ImageType, data []byte) (string, error) {
+	if uploadKey != "" {
+		return uploadKey, nil
+	}
+
+	if uploadKeyTemplate == "" {
+		name := image.GetOutputFilename(file.Path, format != bimg.UNKNOWN, format, uploadTimestamp)
+		return path.Join(uploadPrefix, path.Dir(file.Rel), name), nil
+	}
+
+	vars := image.KeyVars{
+		Name: strings.TrimSuffix(filepath.Base(file.Path), filepath.Ext(file.Path)),
+		Ext:  strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Path), ".")),
+		Hash: image.HashData(data),
+		Time: time.Now(),
+	}
+	if format != bimg.UNKNOWN {
+		vars.Ext = image.Extension(format)
+	}
+	if size, err := bimg.Size(data); err == nil {
+		vars.Width, vars.Height = size.Width, size.Height
+	}
+
+	name, err := image.ExpandKeyTemplate(uploadKeyTemplate, vars)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(uploadPrefix, path.Dir(file.Rel), name), nil
+}
+
+// uploadFile processes a single image, converting it to format unless it is bimg.UNKNOWN,
+// and uploads it with metadata. It returns records of the stored objects and whether the
+// upload was skipped because --dedupe found the same content or --on-conflict=skip found
+// an object already stored.
+func uploadFile(ctx context.Context, store storage.Storage, file inputFile, format bimg.ImageType, metadata map[string]string, out io.Writer) ([]outputRecord, bool, error) {
+	inputPath := file.Path
+
+	// Create image processor
+	processor, err := image.NewProcessor(inputPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Get original image info
+	width0, height0, size, originalFormat := processor.GetOriginalInfo()
+	fmt.Fprintf(out, "Original image: %dx%d, %d bytes, format: %s\n", width0, height0, size, originalFormat)
+
+	processOpts := image.ProcessOptions{
+		Quality:      uploadQuality,
+		Format:       format,
+		KeepMetadata: uploadKeepMetadata,
+		NoRotate:     uploadNoRotate,
+		Watermark:    uploadWatermark.value,
+	}
 
-		// Create S3 client
-		s3Client, err := s3.NewClient(s3Config)
+	// If not converting but still processing for orientation/metadata, keep original format
+	if format == bimg.UNKNOWN {
+		imageType := bimg.DetermineImageType(processor.GetOriginalBuffer())
+		processOpts.Format = imageType
+	}
+
+	putOpts := sourceOptions(filepath.Base(inputPath), processor.GetOriginalBuffer(), metadata)
+	putOpts.IfNotExists = uploadOnConflict != conflictOverwrite
+
+	// Upload one image per width instead of a single image when variants are requested.
+	// Variant keys derive from the key of the original image.
+	if uploadVariantFlags.enabled() {
+		key, err := uploadObjectKey(file, format, processor.GetOriginalBuffer())
 		if err != nil {
-			fmt.Printf("Error: %s\n", err)
-			fmt.Println("Check your S3 configuration in config.toml or environment variables")
-			os.Exit(1)
+			return nil, false, err
 		}
+		records, err := uploadVariants(ctx, store, processor, processOpts, key, &uploadVariantFlags, putOpts, int64(size), out)
+		return records, false, err
+	}
 
-		// Set default key if not provided
-		if uploadKey == "" {
-			uploadKey = image.GetOutputFilename(uploadInputPath, uploadCompress, bimg.WEBP, uploadTimestamp)
+	// Process the image if compression is requested or if we need to handle EXIF orientation/metadata
+	var imageData []byte
+	var enc encoding
+	if len(uploadCandidates) > 0 {
+		// --format auto decides the format, and so the extension of the key
+		processOpts.Resize = uploadResize.value
+		imageData, format, enc, err = uploadTarget.encodeSmallest(processor, processOpts, uploadCandidates, uploadKeepOriginal, out)
+		if err != nil {
+			return nil, false, err
 		}
+	} else if format != bimg.UNKNOWN || !uploadKeepMetadata || !uploadNoRotate || uploadResize.enabled() || uploadTarget.enabled() || uploadWatermark.enabled() {
+		processOpts.Resize = uploadResize.value
 
-		// Upload to S3
-		err = s3Client.UploadFile(uploadKey, imageData)
+		newImage, newEncoding, err := uploadTarget.process(processor, processOpts, out)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return nil, false, err
 		}
 
-		// Get and display the file URL
-		s3URL := s3Client.GetFileURL(uploadKey)
-		fmt.Printf("Successfully uploaded to S3: %s\n", s3URL)
-	},
+		imageData, enc = newImage, newEncoding
+		fmt.Fprintf(out, "Compressed image: %d bytes (%.2f%% of original)\n",
+			len(newImage), float64(len(newImage))/float64(size)*100)
+	} else {
+		// Use original image
+		imageData = processor.GetOriginalBuffer()
+	}
+
+	key, err := uploadObjectKey(file, format, imageData)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Content-addressed keys only exist if the same bytes were uploaded before
+	if uploadDedupe {
+		exists, err := storage.Exists(ctx, store, key)
+		if err != nil {
+			return nil, false, fmt.Errorf("error checking for existing object: %w", err)
+		}
+		if exists {
+			fileURL, err := objectURL(ctx, store, key, uploadPresign)
+			if err != nil {
+				return nil, false, err
+			}
+			// The stored object has the same content
+			record := imageRecord(ctx, store, key, fileURL, imageData, int64(size), statusSkipped)
+			enc.apply(&record)
+			return []outputRecord{record}, true, nil
+		}
+	}
+
+	// Upload to storage, letting the backend reject existing keys atomically
+	key, err = putUpload(ctx, store, key, imageData, putOpts, out)
+	if errors.Is(err, storage.ErrExists) && uploadOnConflict == conflictSkip {
+		fileURL, err := objectURL(ctx, store, key, uploadPresign)
+		if err != nil {
+			return nil, false, err
+		}
+		return []outputRecord{objectRecord(ctx, store, key, fileURL, statusSkipped)}, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	fileURL, err := objectURL(ctx, store, key, uploadPresign)
+	if err != nil {
+		return nil, false, err
+	}
+	record := imageRecord(ctx, store, key, fileURL, imageData, int64(size), statusUploaded)
+	enc.apply(&record)
+	return []outputRecord{record}, false, nil
+}
+
+// putUpload stores data under key following --on-conflict and returns the key that was
+// used, which differs from key when the object was renamed
+func putUpload(ctx context.Context, store storage.Storage, key string, data []byte, putOpts storage.PutOptions, out io.Writer) (string, error) {
+	base, ext := strings.TrimSuffix(key, path.Ext(key)), path.Ext(key)
+
+	candidate := key
+	for attempt := 1; ; attempt++ {
+		err := store.Put(ctx, candidate, bytes.NewReader(data), imageOptions(putOpts, candidate, data))
+		if !errors.Is(err, storage.ErrExists) {
+			return candidate, err
+		}
+
+		switch uploadOnConflict {
+		case conflictRename:
+			if attempt > maxRenameAttempts {
+				return "", errs.Wrap(errs.ErrConflict, fmt.Errorf("no free key found after %d attempts: %s", maxRenameAttempts, key))
+			}
+			candidate = fmt.Sprintf("%s-%d%s", base, attempt, ext)
+			fmt.Fprintf(out, "Object already exists, trying: %s\n", candidate)
+		case conflictSkip:
+			return candidate, err
+		default:
+			return "", errs.Wrap(errs.ErrConflict, fmt.Errorf("object already exists: %s (use --on-conflict=overwrite, skip or rename)", candidate))
+		}
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(uploadCmd)
 
 	// Define command line flags for image processing
-	uploadCmd.Flags().StringVarP(&uploadInputPath, "input", "i", "", "Path to the input image file (required)")
-	uploadCmd.Flags().StringVarP(&uploadKey, "key", "k", "", "S3 object key (path in bucket)")
+	uploadCmd.Flags().StringArrayVarP(&uploadInputPaths, "input", "i", nil, "Input image file, directory or glob pattern (repeatable)")
+	uploadCmd.Flags().StringVarP(&uploadKey, "key", "k", "", "Object key (path in bucket), only for a single input file")
+	uploadCmd.Flags().StringVarP(&uploadPrefix, "prefix", "p", "", "Key prefix for uploaded objects (e.g., 'blog/2026/')")
 	uploadCmd.Flags().BoolVarP(&uploadCompress, "compress", "c", false, "Compress image before uploading")
+	uploadCmd.Flags().StringVarP(&uploadFormat, "format", "f", "", "Convert to format ("+strings.Join(image.FormatNames(), ", ")+"), or auto for the smallest candidate; defaults to webp with --compress")
 	uploadCmd.Flags().IntVarP(&uploadQuality, "quality", "q", 80, "Quality of the compressed image (1-100)")
-	uploadCmd.Flags().StringVarP(&uploadResize, "resize", "r", "", "Resize image to width,height (e.g., '800,600'). Use 0 for any dimension to maintain aspect ratio")
+	addResizeFlags(uploadCmd, &uploadResize)
 	uploadCmd.Flags().BoolVarP(&uploadTimestamp, "timestamp", "t", false, "Use timestamp as filename when key is not specified")
+	uploadCmd.Flags().StringVar(&uploadKeyTemplate, "key-template", "", "Key template using {hash}, {hash:8}, {name}, {ext}, {date:2006/01}, {w} and {h}")
+	uploadCmd.Flags().StringVar(&uploadOnConflict, "on-conflict", conflictFail, "What to do when an object already exists under the key: fail, overwrite, skip or rename")
+	uploadCmd.Flags().BoolVar(&uploadDedupe, "dedupe", false, "Skip uploads whose content is already stored, using content-addressed keys ("+defaultDedupeKeyTemplate+" by default)")
 	uploadCmd.Flags().BoolVar(&uploadKeepMetadata, "keep-metadata", false, "Keep image metadata (EXIF, etc.)")
 	uploadCmd.Flags().BoolVar(&uploadNoRotate, "no-rotate", false, "Disable automatic rotation based on EXIF orientation")
-
-	// Mark required flags
-	uploadCmd.MarkFlagRequired("input")
+	uploadCmd.Flags().IntVarP(&uploadJobs, "jobs", "j", defaultJobs, "Number of files to process and upload concurrently")
+	uploadCmd.Flags().DurationVar(&uploadPresign, "presign", 0, "Print presigned download URLs valid for this duration (e.g., 24h) for private buckets")
+	addMetadataFlag(uploadCmd, &uploadMeta)
+	addVariantFlags(uploadCmd, &uploadVariantFlags)
+	addTargetFlags(uploadCmd, &uploadTarget)
+	addWatermarkFlags(uploadCmd, &uploadWatermark)
+	uploadCmd.Flags().StringVar(&uploadAutoFormats, "auto-formats", "", "Comma-separated candidates of --format auto (default from auto_formats, or "+strings.Join(image.DefaultAutoFormats, ",")+")")
+	uploadCmd.Flags().BoolVar(&uploadKeepOriginal, "keep-original", false, "With --format auto, store the original unchanged when no candidate is smaller")
 
 	// Add shell completion for flags
 	_ = uploadCmd.RegisterFlagCompletionFunc("input", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return nil, cobra.ShellCompDirectiveDefault
 	})
+	_ = uploadCmd.RegisterFlagCompletionFunc("on-conflict", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{conflictFail, conflictOverwrite, conflictSkip, conflictRename}, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = uploadCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return append(image.FormatNames(), image.AutoFormat), cobra.ShellCompDirectiveNoFileComp
+	})
 }
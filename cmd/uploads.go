@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+var (
+	uploadsPrefix    string
+	uploadsOlderThan time.Duration
+	uploadsAbort     bool
+)
+
+var uploadsCmd = &cobra.Command{
+	Use:   "uploads",
+	Short: "List or abort incomplete multipart uploads",
+	Long: `List incomplete multipart uploads, or abort them with --abort.
+
+Large files are uploaded in parts. When an upload is interrupted, for
+example by a crash or a lost connection, its parts stay in the bucket and
+are billed until the upload is aborted. imgood aborts its own failed
+uploads, so this is only needed to clean up after interrupted runs.
+
+Interrupted uploads cannot be resumed, since the uploaded content is the
+processed image rather than the local file. Run "up" again and abort the
+incomplete upload instead.
+
+Example:
+  imgood uploads
+  imgood uploads --abort --older-than 24h
+  imgood uploads --abort -p raw/`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Create storage backend
+		store, err := newStorage(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		manager, ok := store.(storage.UploadManager)
+		if !ok {
+			return errs.Wrap(errs.ErrConfig, fmt.Errorf("multipart uploads are not supported by the %s backend", config.GetBackend()))
+		}
+
+		// Uploads still in progress in another process are spared by --older-than
+		cutoff := time.Now().Add(-uploadsOlderThan)
+		var uploads []storage.Upload
+		err = manager.ListUploads(cmd.Context(), uploadsPrefix, func(upload storage.Upload) error {
+			if uploadsOlderThan == 0 || upload.Initiated.Before(cutoff) {
+				uploads = append(uploads, upload)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w := humanOutput()
+		records := newRecordWriter()
+		if len(uploads) == 0 {
+			fmt.Fprintln(w, "No incomplete uploads found.")
+			return nil
+		}
+
+		// Structured output replaces the table with one record per upload
+		if !uploadsAbort {
+			if !structuredOutput() {
+				fmt.Printf("%-50s %-20s %s\n", "KEY", "INITIATED", "UPLOAD ID")
+			}
+			listed := make([]outputRecord, 0, len(uploads))
+			for _, upload := range uploads {
+				listed = append(listed, uploadRecord(upload, statusIncomplete))
+				if !structuredOutput() {
+					fmt.Printf("%-50s %-20s %s\n", upload.Key, upload.Initiated.Format("2006-01-02 15:04:05"), upload.ID)
+				}
+			}
+			records.writeAll(listed)
+			fmt.Fprintf(w, "\nTotal: %d incomplete uploads, use --abort to remove them\n", len(uploads))
+			return nil
+		}
+
+		var failures []error
+		var aborted []outputRecord
+		for _, upload := range uploads {
+			if err := manager.AbortUpload(cmd.Context(), upload); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				failures = append(failures, err)
+				continue
+			}
+			fmt.Fprintf(w, "aborted: %s\n", upload.Key)
+			aborted = append(aborted, uploadRecord(upload, statusAborted))
+		}
+		records.writeAll(aborted)
+
+		fmt.Fprintf(w, "\nAborted %d of %d uploads, %d failed\n", len(uploads)-len(failures), len(uploads), len(failures))
+		if len(failures) > 0 {
+			return &reportedError{err: errors.Join(failures...)}
+		}
+		return nil
+	},
+}
+
+// uploadRecord describes an incomplete multipart upload
+func uploadRecord(upload storage.Upload, status string) outputRecord {
+	return outputRecord{Key: upload.Key, UploadID: upload.ID, Initiated: formatRecordTime(upload.Initiated), Status: status}
+}
+
+func init() {
+	rootCmd.AddCommand(uploadsCmd)
+
+	// Define command line flags for multipart upload cleanup
+	uploadsCmd.Flags().StringVarP(&uploadsPrefix, "prefix", "p", "", "Only include uploads with this key prefix")
+	uploadsCmd.Flags().DurationVar(&uploadsOlderThan, "older-than", 0, "Only include uploads started longer ago than this (e.g., 24h)")
+	uploadsCmd.Flags().BoolVar(&uploadsAbort, "abort", false, "Abort the uploads and discard their parts")
+}
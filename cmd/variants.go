@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mingeme/imgood/internal/image"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+// variantFlags holds the responsive variant options shared by up and cp
+type variantFlags struct {
+	widths  string
+	naming  string
+	snippet string
+	sizes   string
+}
+
+// addVariantFlags registers the responsive variant flags on cmd
+func addVariantFlags(cmd *cobra.Command, v *variantFlags) {
+	cmd.Flags().StringVar(&v.widths, "variants", "", "Comma-separated widths of responsive variants to create (e.g., '320,640,1280')")
+	cmd.Flags().StringVar(&v.naming, "variant-name", image.DefaultVariantName, "Key template for variants using {name}, {w} and {ext}")
+	cmd.Flags().StringVar(&v.snippet, "snippet", "img", "HTML snippet printed for variants: img or picture")
+	cmd.Flags().StringVar(&v.sizes, "sizes", "100vw", "Value of the sizes attribute in the HTML snippet")
+
+	_ = cmd.RegisterFlagCompletionFunc("snippet", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"img", "picture"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// enabled reports whether variants were requested
+func (v *variantFlags) enabled() bool {
+	return v.widths != ""
+}
+
+// validate checks the variant flags before any work is started
+func (v *variantFlags) validate() error {
+	if !v.enabled() {
+		return nil
+	}
+	if _, err := image.ParseWidths(v.widths); err != nil {
+		return err
+	}
+	if !strings.Contains(v.naming, "{w}") {
+		return fmt.Errorf("--variant-name must contain {w} so variant keys differ")
+	}
+	if v.snippet != "img" && v.snippet != "picture" {
+		return fmt.Errorf("unsupported snippet: %s (expected img or picture)", v.snippet)
+	}
+	return nil
+}
+
+// uploadVariants encodes one variant per width, uploads each under a key derived from
+// key with the naming template and putOpts, prints an HTML snippet and returns records of
+// the variants created from a source of originalSize bytes, ordered by width
+func uploadVariants(ctx context.Context, store storage.Storage, processor *image.Processor, opts image.ProcessOptions, key string, v *variantFlags, putOpts storage.PutOptions, originalSize int64, out io.Writer) ([]outputRecord, error) {
+	widths, err := image.ParseWidths(v.widths)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := processor.ProcessVariants(opts, widths)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(key, path.Ext(key))
+	ext := image.Extension(opts.Format)
+
+	entries := make([]image.SrcsetEntry, 0, len(variants))
+	records := make([]outputRecord, 0, len(variants))
+	for _, variant := range variants {
+		variantKey := image.VariantKey(v.naming, name, variant.Width, ext)
+		if err := store.Put(ctx, variantKey, bytes.NewReader(variant.Data), imageOptions(putOpts, variantKey, variant.Data)); err != nil {
+			return nil, fmt.Errorf("error uploading %s: %w", variantKey, err)
+		}
+
+		url := store.URL(variantKey)
+		fmt.Fprintf(out, "Variant %dx%d: %d bytes -> %s\n", variant.Width, variant.Height, len(variant.Data), url)
+		entries = append(entries, image.SrcsetEntry{URL: url, Width: variant.Width, Height: variant.Height})
+		records = append(records, imageRecord(ctx, store, variantKey, url, variant.Data, originalSize, statusUploaded))
+	}
+
+	if v.snippet == "picture" {
+		fmt.Fprintln(out, image.PictureSnippet(entries, v.sizes, opts.Format))
+	} else {
+		fmt.Fprintln(out, image.ImgSnippet(entries, v.sizes))
+	}
+
+	return records, nil
+}
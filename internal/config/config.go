@@ -5,10 +5,17 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// Supported storage backends
+const (
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
 // S3Config holds S3 configuration settings
 type S3Config struct {
 	Bucket    string
@@ -16,6 +23,47 @@ type S3Config struct {
 	Region    string
 	AccessKey string
 	SecretKey string
+	// PublicURL is a template such as "https://img.example.com/{key}" for object URLs
+	PublicURL string
+	// PathStyle addresses the bucket in the URL path instead of the host name
+	PathStyle bool
+	// ConditionalWrites uses If-None-Match for uploads that must not replace objects,
+	// otherwise existence is checked before writing
+	ConditionalWrites bool
+	// MultipartThreshold is the size from which uploads are split into parts
+	MultipartThreshold int64
+	// PartSize is the size of each part of a multipart upload
+	PartSize int64
+	// PartConcurrency is the number of parts uploaded in parallel
+	PartConcurrency int
+	// Timeout limits each request to the S3 API, 0 means no limit
+	Timeout time.Duration
+}
+
+// CacheControlRule sets the Cache-Control header of objects below a key prefix
+type CacheControlRule struct {
+	Prefix string `mapstructure:"prefix"`
+	Value  string `mapstructure:"value"`
+}
+
+// LocalConfig holds local filesystem storage settings
+type LocalConfig struct {
+	Root    string
+	BaseURL string
+}
+
+// WatermarkConfig holds the default watermark of uploads and copies. Empty and zero
+// values are not configured.
+type WatermarkConfig struct {
+	Text     string
+	Image    string
+	Font     string
+	Size     int
+	Color    string
+	Scale    float64
+	Opacity  float64
+	Position string
+	Margin   int
 }
 
 // Init initializes the configuration from config file and environment variables
@@ -34,23 +82,98 @@ func Init() error {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
+	viper.SetDefault("backend", BackendS3)
+	viper.SetDefault("local.root", ".")
+	viper.SetDefault("s3.conditional_writes", true)
+	viper.SetDefault("s3.multipart_threshold", "64MB")
+	viper.SetDefault("s3.part_size", "16MB")
+	viper.SetDefault("s3.part_concurrency", 4)
+
 	err = viper.ReadInConfig()
 	if err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return fmt.Errorf("error reading config file: %w", err)
 		}
 	}
+	fileSettings = topLevelSettings()
 
 	return nil
 }
 
+// GetBackend returns the name of the configured storage backend
+func GetBackend() string {
+	return strings.ToLower(viper.GetString("backend"))
+}
+
 // GetS3Config returns the S3 configuration from viper
 func GetS3Config() S3Config {
 	return S3Config{
-		Bucket:    viper.GetString("s3.bucket"),
-		Endpoint:  viper.GetString("s3.endpoint"),
-		Region:    viper.GetString("s3.region"),
-		AccessKey: viper.GetString("s3.access_key"),
-		SecretKey: viper.GetString("s3.secret_key"),
+		Bucket:             viper.GetString("s3.bucket"),
+		Endpoint:           viper.GetString("s3.endpoint"),
+		Region:             viper.GetString("s3.region"),
+		AccessKey:          viper.GetString("s3.access_key"),
+		SecretKey:          viper.GetString("s3.secret_key"),
+		PublicURL:          viper.GetString("s3.public_url"),
+		PathStyle:          viper.GetBool("s3.path_style"),
+		ConditionalWrites:  viper.GetBool("s3.conditional_writes"),
+		MultipartThreshold: int64(viper.GetSizeInBytes("s3.multipart_threshold")),
+		PartSize:           int64(viper.GetSizeInBytes("s3.part_size")),
+		PartConcurrency:    viper.GetInt("s3.part_concurrency"),
+		Timeout:            viper.GetDuration("timeout"),
+	}
+}
+
+// GetLocalConfig returns the local filesystem storage configuration from viper
+func GetLocalConfig() LocalConfig {
+	return LocalConfig{
+		Root:    viper.GetString("local.root"),
+		BaseURL: viper.GetString("local.base_url"),
+	}
+}
+
+// GetWatermarkConfig returns the default watermark from the watermark table
+func GetWatermarkConfig() WatermarkConfig {
+	return WatermarkConfig{
+		Text:     viper.GetString("watermark.text"),
+		Image:    viper.GetString("watermark.image"),
+		Font:     viper.GetString("watermark.font"),
+		Size:     viper.GetInt("watermark.size"),
+		Color:    viper.GetString("watermark.color"),
+		Scale:    viper.GetFloat64("watermark.scale"),
+		Opacity:  viper.GetFloat64("watermark.opacity"),
+		Position: viper.GetString("watermark.position"),
+		Margin:   viper.GetInt("watermark.margin"),
+	}
+}
+
+// GetAutoFormats returns the candidate formats of --format auto from auto_formats, given as
+// a list or a comma-separated string, or nil if not configured
+func GetAutoFormats() []string {
+	if value, ok := viper.Get("auto_formats").(string); ok {
+		var formats []string
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				formats = append(formats, name)
+			}
+		}
+		return formats
+	}
+	return viper.GetStringSlice("auto_formats")
+}
+
+// GetCacheControl returns the Cache-Control header for an object key. The rule with
+// the longest matching prefix in cache_control_rules wins, cache_control is the default.
+func GetCacheControl(key string) string {
+	var rules []CacheControlRule
+	if err := viper.UnmarshalKey("cache_control_rules", &rules); err != nil {
+		return viper.GetString("cache_control")
+	}
+
+	value, matched := viper.GetString("cache_control"), -1
+	for _, rule := range rules {
+		if strings.HasPrefix(key, rule.Prefix) && len(rule.Prefix) > matched {
+			value, matched = rule.Value, len(rule.Prefix)
+		}
 	}
+	return value
 }
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/mingeme/imgood/internal/errs"
+)
+
+// Profile describes a named profile from the [profiles.<name>] tables, with
+// inherited settings resolved
+type Profile struct {
+	Name     string
+	Inherits string
+	Default  bool
+	Backend  string
+	Bucket   string
+	Endpoint string
+	Root     string
+	// Err is set when the profile cannot be resolved, e.g. because of an inheritance cycle
+	Err error
+}
+
+var (
+	// activeProfile is the profile applied by UseProfile
+	activeProfile string
+	// fileSettings holds the top-level settings as loaded by Init, before any profile is applied
+	fileSettings map[string]interface{}
+)
+
+// DefaultProfile returns the profile used when --profile is not given
+func DefaultProfile() string {
+	return strings.ToLower(viper.GetString("default_profile"))
+}
+
+// ActiveProfile returns the name of the applied profile, or "" when none is applied
+func ActiveProfile() string {
+	return activeProfile
+}
+
+// ProfileNames returns the names of all configured profiles in alphabetical order
+func ProfileNames() []string {
+	var names []string
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseProfile applies the settings of a profile and the profiles it inherits from on top
+// of the top-level settings. Environment variables and flags still take precedence.
+// An empty name selects the default profile, if one is configured.
+func UseProfile(name string) error {
+	// Config keys, and so profile names, are case-insensitive
+	name = strings.ToLower(name)
+	if name == "" {
+		name = DefaultProfile()
+	}
+	if name == "" {
+		return nil
+	}
+
+	settings, err := profileSettings(name)
+	if err != nil {
+		return errs.Wrap(errs.ErrConfig, err)
+	}
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return errs.Wrap(errs.ErrConfig, fmt.Errorf("error applying profile %s: %w", name, err))
+	}
+
+	activeProfile = name
+	return nil
+}
+
+// ListProfiles returns all configured profiles with their resolved settings
+func ListProfiles() ([]Profile, error) {
+	var profiles []Profile
+	for _, name := range ProfileNames() {
+		profile := Profile{
+			Name:     name,
+			Inherits: strings.ToLower(viper.GetString("profiles." + name + ".inherits")),
+			Default:  name == DefaultProfile(),
+		}
+
+		settings, err := profileSettings(name)
+		if err != nil {
+			profile.Err = err
+			profiles = append(profiles, profile)
+			continue
+		}
+
+		// Resolve the profile on top of the top-level settings without touching the global config
+		v := viper.New()
+		v.SetDefault("backend", BackendS3)
+		v.SetDefault("local.root", ".")
+		if err := v.MergeConfigMap(copyMap(fileSettings)); err != nil {
+			return nil, err
+		}
+		if err := v.MergeConfigMap(settings); err != nil {
+			return nil, err
+		}
+
+		profile.Backend = strings.ToLower(v.GetString("backend"))
+		profile.Bucket = v.GetString("s3.bucket")
+		profile.Endpoint = v.GetString("s3.endpoint")
+		profile.Root = v.GetString("local.root")
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// profileSettings merges the settings of a profile with the profiles it inherits from,
+// the profile's own settings taking precedence over inherited ones
+func profileSettings(name string) (map[string]interface{}, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for current := name; current != ""; current = strings.ToLower(viper.GetString("profiles." + current + ".inherits")) {
+		if seen[current] {
+			return nil, fmt.Errorf("profile %s inherits from itself through %s", name, strings.Join(chain, " -> "))
+		}
+		if !viper.IsSet("profiles." + current) {
+			if current == name {
+				return nil, fmt.Errorf("profile not found: %s", name)
+			}
+			return nil, fmt.Errorf("profile %s inherits from unknown profile %s", chain[len(chain)-1], current)
+		}
+		seen[current] = true
+		chain = append(chain, current)
+	}
+
+	settings := make(map[string]interface{})
+	for i := len(chain) - 1; i >= 0; i-- {
+		profile := copyMap(viper.GetStringMap("profiles." + chain[i]))
+		delete(profile, "inherits")
+		mergeMap(settings, profile)
+	}
+	return settings, nil
+}
+
+// topLevelSettings returns a copy of the config file settings outside of the profiles table
+func topLevelSettings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	for _, key := range []string{"backend", "timeout", "cache_control", "cache_control_rules", "auto_formats", "watermark", "s3", "local"} {
+		if value := viper.Get(key); value != nil {
+			settings[key] = value
+		}
+	}
+	return copyMap(settings)
+}
+
+// copyMap deep copies nested settings so merging never modifies the loaded config
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = copyMap(nested)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// mergeMap merges src into dst, recursing into nested tables
+func mergeMap(dst, src map[string]interface{}) {
+	for key, value := range src {
+		nested, ok := value.(map[string]interface{})
+		existing, isMap := dst[key].(map[string]interface{})
+		if ok && isMap {
+			mergeMap(existing, nested)
+			continue
+		}
+		dst[key] = value
+	}
+}
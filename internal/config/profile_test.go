@@ -0,0 +1,218 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const profilesConfig = `
+default_profile = "staging"
+
+[s3]
+bucket = "images"
+region = "us-east-1"
+
+[profiles.minio.s3]
+endpoint = "https://minio.example.com"
+path_style = true
+
+[profiles.staging]
+inherits = "minio"
+[profiles.staging.s3]
+bucket = "images-staging"
+
+[profiles.prod]
+inherits = "Staging"
+[profiles.prod.s3]
+bucket = "images-prod"
+
+[profiles.local]
+backend = "local"
+[profiles.local.local]
+root = "/srv/images"
+
+[profiles.loop-a]
+inherits = "loop-b"
+[profiles.loop-b]
+inherits = "loop-a"
+
+[profiles.self]
+inherits = "self"
+
+[profiles.orphan]
+inherits = "missing"
+`
+
+// loadConfig replaces the global configuration with the given TOML document
+func loadConfig(t *testing.T, document string) {
+	t.Helper()
+	viper.Reset()
+	activeProfile = ""
+	t.Cleanup(func() {
+		viper.Reset()
+		activeProfile = ""
+		fileSettings = nil
+	})
+
+	viper.SetConfigType("toml")
+	viper.SetDefault("backend", BackendS3)
+	viper.SetDefault("local.root", ".")
+	if err := viper.ReadConfig(strings.NewReader(document)); err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	fileSettings = topLevelSettings()
+}
+
+func TestUseProfile(t *testing.T) {
+	tests := []struct {
+		profile  string
+		active   string
+		bucket   string
+		endpoint string
+		backend  string
+		region   string
+	}{
+		{profile: "", active: "staging", bucket: "images-staging", endpoint: "https://minio.example.com", backend: "s3", region: "us-east-1"},
+		{profile: "minio", active: "minio", bucket: "images", endpoint: "https://minio.example.com", backend: "s3", region: "us-east-1"},
+		{profile: "prod", active: "prod", bucket: "images-prod", endpoint: "https://minio.example.com", backend: "s3", region: "us-east-1"},
+		{profile: "PROD", active: "prod", bucket: "images-prod", endpoint: "https://minio.example.com", backend: "s3", region: "us-east-1"},
+		{profile: "local", active: "local", bucket: "images", backend: "local", region: "us-east-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			loadConfig(t, profilesConfig)
+			if err := UseProfile(tt.profile); err != nil {
+				t.Fatalf("UseProfile(%q) error: %v", tt.profile, err)
+			}
+
+			if got := ActiveProfile(); got != tt.active {
+				t.Errorf("ActiveProfile() = %q, want %q", got, tt.active)
+			}
+			s3 := GetS3Config()
+			if s3.Bucket != tt.bucket {
+				t.Errorf("bucket = %q, want %q", s3.Bucket, tt.bucket)
+			}
+			if s3.Endpoint != tt.endpoint {
+				t.Errorf("endpoint = %q, want %q", s3.Endpoint, tt.endpoint)
+			}
+			if s3.Region != tt.region {
+				t.Errorf("region = %q, want %q", s3.Region, tt.region)
+			}
+			if got := GetBackend(); got != tt.backend {
+				t.Errorf("backend = %q, want %q", got, tt.backend)
+			}
+		})
+	}
+}
+
+func TestUseProfileWithoutDefault(t *testing.T) {
+	loadConfig(t, "[s3]\nbucket = \"images\"\n")
+	if err := UseProfile(""); err != nil {
+		t.Fatalf("UseProfile(\"\") error: %v", err)
+	}
+	if got := ActiveProfile(); got != "" {
+		t.Errorf("ActiveProfile() = %q, want no profile", got)
+	}
+	if got := GetS3Config().Bucket; got != "images" {
+		t.Errorf("bucket = %q, want %q", got, "images")
+	}
+}
+
+func TestProfileSettingsErrors(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{profile: "loop-a", want: "profile loop-a inherits from itself through loop-a -> loop-b"},
+		{profile: "loop-b", want: "profile loop-b inherits from itself through loop-b -> loop-a"},
+		{profile: "self", want: "profile self inherits from itself through self"},
+		{profile: "orphan", want: "profile orphan inherits from unknown profile missing"},
+		{profile: "unknown", want: "profile not found: unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			loadConfig(t, profilesConfig)
+			_, err := profileSettings(tt.profile)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("profileSettings(%q) error = %v, want %q", tt.profile, err, tt.want)
+			}
+			if err := UseProfile(tt.profile); err == nil {
+				t.Errorf("UseProfile(%q) succeeded, want an error", tt.profile)
+			}
+		})
+	}
+}
+
+func TestProfileSettingsDoNotModifyConfig(t *testing.T) {
+	loadConfig(t, profilesConfig)
+	if _, err := profileSettings("prod"); err != nil {
+		t.Fatalf("profileSettings error: %v", err)
+	}
+	if got := viper.GetString("profiles.staging.s3.bucket"); got != "images-staging" {
+		t.Errorf("staging bucket = %q after resolving prod, want %q", got, "images-staging")
+	}
+	if viper.IsSet("profiles.minio.s3.bucket") {
+		t.Errorf("minio gained a bucket after resolving prod")
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	loadConfig(t, profilesConfig)
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatalf("ListProfiles error: %v", err)
+	}
+
+	byName := make(map[string]Profile)
+	var names []string
+	for _, profile := range profiles {
+		byName[profile.Name] = profile
+		names = append(names, profile.Name)
+	}
+	if got, want := strings.Join(names, ","), "local,loop-a,loop-b,minio,orphan,prod,self,staging"; got != want {
+		t.Errorf("profile names = %s, want %s", got, want)
+	}
+
+	prod := byName["prod"]
+	if prod.Err != nil || prod.Bucket != "images-prod" || prod.Endpoint != "https://minio.example.com" || prod.Inherits != "staging" || prod.Backend != "s3" {
+		t.Errorf("prod = %+v", prod)
+	}
+	if !byName["staging"].Default || prod.Default {
+		t.Errorf("staging should be the only default profile")
+	}
+	if local := byName["local"]; local.Backend != "local" || local.Root != "/srv/images" {
+		t.Errorf("local = %+v", local)
+	}
+	for _, name := range []string{"loop-a", "loop-b", "self", "orphan"} {
+		if byName[name].Err == nil {
+			t.Errorf("%s resolved without error", name)
+		}
+	}
+}
+
+func TestMergeMap(t *testing.T) {
+	dst := map[string]interface{}{
+		"backend": "s3",
+		"s3":      map[string]interface{}{"bucket": "a", "region": "eu"},
+	}
+	src := map[string]interface{}{
+		"s3":    map[string]interface{}{"bucket": "b"},
+		"local": map[string]interface{}{"root": "/srv"},
+	}
+	mergeMap(dst, src)
+
+	s3 := dst["s3"].(map[string]interface{})
+	if s3["bucket"] != "b" || s3["region"] != "eu" {
+		t.Errorf("s3 = %v, want bucket b and region eu", s3)
+	}
+	if dst["backend"] != "s3" {
+		t.Errorf("backend = %v, want s3", dst["backend"])
+	}
+	if local, ok := dst["local"].(map[string]interface{}); !ok || local["root"] != "/srv" {
+		t.Errorf("local = %v, want root /srv", dst["local"])
+	}
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Setting describes a configuration key that can be shown and set
+type Setting struct {
+	Key         string
+	Description string
+	// Type of the value, TypeString when empty
+	Type string
+	// Secret settings are masked when shown
+	Secret bool
+}
+
+// Value types of settings, which decide how "config set" writes a value
+const (
+	TypeString   = ""
+	TypeBool     = "bool"
+	TypeInt      = "int"
+	TypeFloat    = "float"
+	TypeDuration = "duration"
+	TypeList     = "list"
+)
+
+// Settings lists the known configuration keys in display order
+var Settings = []Setting{
+	{Key: "backend", Description: "Storage backend: s3 or local"},
+	{Key: "default_profile", Description: "Profile used when --profile is not given"},
+	{Key: "timeout", Description: "Timeout for each storage request, e.g. 30s", Type: TypeDuration},
+	{Key: "cache_control", Description: "Default Cache-Control header of uploaded objects"},
+	{Key: "auto_formats", Description: "Candidate formats of --format auto, e.g. webp,avif,jpeg,png", Type: TypeList},
+	{Key: "watermark.text", Description: "Default watermark text of up and cp"},
+	{Key: "watermark.image", Description: "Default watermark image of up and cp, a local PNG file"},
+	{Key: "watermark.font", Description: "Font of the watermark text, e.g. sans bold"},
+	{Key: "watermark.size", Description: "Height of the watermark text in pixels", Type: TypeInt},
+	{Key: "watermark.color", Description: "Color of the watermark text, e.g. #ffffff"},
+	{Key: "watermark.scale", Description: "Width of the watermark image relative to the image width, e.g. 0.2", Type: TypeFloat},
+	{Key: "watermark.opacity", Description: "Opacity of the watermark from 0 to 1", Type: TypeFloat},
+	{Key: "watermark.position", Description: "Position of the watermark, e.g. southeast"},
+	{Key: "watermark.margin", Description: "Distance of the watermark to the image edges in pixels", Type: TypeInt},
+	{Key: "s3.bucket", Description: "S3 bucket name"},
+	{Key: "s3.endpoint", Description: "S3 endpoint URL for non-AWS services"},
+	{Key: "s3.region", Description: "AWS region"},
+	{Key: "s3.public_url", Description: "Template for object URLs, e.g. https://img.example.com/{key}"},
+	{Key: "s3.path_style", Description: "Use path-style URLs (endpoint/bucket/key), e.g. for MinIO", Type: TypeBool},
+	{Key: "s3.conditional_writes", Description: "Use If-None-Match: * to avoid replacing objects (default true). Services that ignore the header replace objects anyway, disable it for them", Type: TypeBool},
+	{Key: "s3.multipart_threshold", Description: "Size from which uploads are split into parts, e.g. 64MB"},
+	{Key: "s3.part_size", Description: "Part size of multipart uploads, at least 5MB"},
+	{Key: "s3.part_concurrency", Description: "Number of parts uploaded in parallel", Type: TypeInt},
+	{Key: "s3.access_key", Description: "Access key ID", Secret: true},
+	{Key: "s3.secret_key", Description: "Secret access key", Secret: true},
+	{Key: "local.root", Description: "Directory of the local backend"},
+	{Key: "local.base_url", Description: "Public URL prefix of the local backend"},
+}
+
+// Value sources reported by Source
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceProfile = "profile"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// boundFlags records the flags bound with BindFlag so Source can report them
+var boundFlags = make(map[string]*pflag.Flag)
+
+// BindFlag binds a command line flag to a configuration key
+func BindFlag(key string, flag *pflag.Flag) error {
+	boundFlags[key] = flag
+	return viper.BindPFlag(key, flag)
+}
+
+// LookupSetting returns the setting for a key. Keys below a profile, such as
+// "profiles.prod.s3.bucket", resolve to the top-level setting.
+func LookupSetting(key string) (Setting, bool) {
+	key = strings.ToLower(key)
+	if rest, ok := strings.CutPrefix(key, "profiles."); ok {
+		_, profileKey, found := strings.Cut(rest, ".")
+		if !found {
+			return Setting{}, false
+		}
+		if profileKey == "inherits" {
+			return Setting{Key: key, Description: "Profile to inherit settings from"}, true
+		}
+		setting, ok := LookupSetting(profileKey)
+		setting.Key = key
+		return setting, ok && profileKey != "default_profile"
+	}
+
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Parse converts a value given on the command line to the type of the setting. Durations
+// are checked and kept as strings, lists are split at commas.
+func (s Setting) Parse(value string) (interface{}, error) {
+	switch s.Type {
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s (expected true or false)", s.Key, value)
+		}
+		return b, nil
+	case TypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s (expected an integer)", s.Key, value)
+		}
+		return n, nil
+	case TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s (expected a number)", s.Key, value)
+		}
+		return f, nil
+	case TypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s (expected a duration such as 30s)", s.Key, value)
+		}
+		return value, nil
+	case TypeList:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+	return value, nil
+}
+
+// Source reports where the effective value of a key comes from
+func Source(key string) string {
+	if flag, ok := boundFlags[key]; ok && flag.Changed {
+		return SourceFlag
+	}
+	if _, ok := os.LookupEnv(EnvVar(key)); ok {
+		return SourceEnv
+	}
+	if activeProfile != "" {
+		if settings, err := profileSettings(activeProfile); err == nil && hasKey(settings, key) {
+			return SourceProfile
+		}
+	}
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// EnvVar returns the environment variable that overrides a key
+func EnvVar(key string) string {
+	return "IMGOOD_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Mask hides all but the last four characters of a secret
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
+
+// File returns the config file in use, or "" when none was found
+func File() string {
+	return viper.ConfigFileUsed()
+}
+
+// DefaultFile returns the config file created by "config init"
+func DefaultFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "imgood", "config.toml"), nil
+}
+
+// WriteFile writes values to a new config file, replacing an existing file
+func WriteFile(path string, values map[string]string) error {
+	v := viper.New()
+	for key, value := range values {
+		typed, err := parseValue(key, value)
+		if err != nil {
+			return err
+		}
+		v.Set(key, typed)
+	}
+	return writeConfig(v, path)
+}
+
+// SetValue sets a key in a config file, keeping its other settings.
+// Comments in the file are not preserved.
+func SetValue(path, key, value string) error {
+	typed, err := parseValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	// Use a separate instance so defaults, env variables and flags are not written
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	v.Set(key, typed)
+	return writeConfig(v, path)
+}
+
+// parseValue converts a value to the type of the setting for key
+func parseValue(key, value string) (interface{}, error) {
+	setting, ok := LookupSetting(key)
+	if !ok {
+		return nil, fmt.Errorf("unknown config key: %s", key)
+	}
+	return setting.Parse(value)
+}
+
+// writeConfig writes v as TOML to path, readable only by the owner since it may hold secrets
+func writeConfig(v *viper.Viper, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+
+	v.SetConfigType("toml")
+	v.SetConfigPermissions(0o600)
+	if err := v.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	// The permissions only apply to new files, so tighten those of an existing file too
+	if err := os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("error setting config file permissions: %w", err)
+	}
+	return nil
+}
+
+// hasKey reports whether a dotted key is present in nested settings
+func hasKey(settings map[string]interface{}, key string) bool {
+	head, rest, nested := strings.Cut(key, ".")
+	value, ok := settings[head]
+	if !ok || !nested {
+		return ok
+	}
+	m, ok := value.(map[string]interface{})
+	return ok && hasKey(m, rest)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettingParse(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		want    interface{}
+		wantErr bool
+	}{
+		{key: "s3.bucket", value: "images", want: "images"},
+		{key: "s3.path_style", value: "true", want: true},
+		{key: "profiles.prod.s3.conditional_writes", value: "false", want: false},
+		{key: "s3.path_style", value: "yes please", wantErr: true},
+		{key: "s3.part_concurrency", value: "8", want: int64(8)},
+		{key: "s3.part_concurrency", value: "8.5", wantErr: true},
+		{key: "watermark.opacity", value: "0.5", want: 0.5},
+		{key: "watermark.opacity", value: "half", wantErr: true},
+		{key: "timeout", value: "30s", want: "30s"},
+		{key: "timeout", value: "30", wantErr: true},
+		{key: "auto_formats", value: "webp, avif,,png", want: []string{"webp", "avif", "png"}},
+		{key: "s3.multipart_threshold", value: "64MB", want: "64MB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			got, err := parseValue(tt.key, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseValue(%q, %q) = %v, want an error", tt.key, tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseValue(%q, %q) error: %v", tt.key, tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseValue(%q, %q) = %#v, want %#v", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetValueWritesTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	for _, kv := range [][2]string{
+		{"s3.path_style", "true"},
+		{"s3.part_concurrency", "8"},
+		{"timeout", "30s"},
+	} {
+		if err := SetValue(path, kv[0], kv[1]); err != nil {
+			t.Fatalf("SetValue(%s, %s) error: %v", kv[0], kv[1], err)
+		}
+	}
+	if err := SetValue(path, "s3.unknown", "x"); err == nil {
+		t.Errorf("SetValue accepted an unknown key")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"path_style = true", "part_concurrency = 8", "timeout = '30s'"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("config file does not contain %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestSetValueRestrictsPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("backend = 's3'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetValue(path, "s3.secret_key", "secret"); err != nil {
+		t.Fatalf("SetValue error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("config file permissions = %o, want 600", perm)
+	}
+}
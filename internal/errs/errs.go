@@ -0,0 +1,45 @@
+// Package errs defines the error categories that decide the exit code of imgood.
+// Errors are tagged where they are detected and matched with errors.Is.
+package errs
+
+import "errors"
+
+var (
+	// ErrNotFound means an object or file does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means an object already exists where a new one would be written
+	ErrConflict = errors.New("conflict")
+	// ErrAuth means the credentials are missing, invalid or lack permissions
+	ErrAuth = errors.New("authentication failed")
+	// ErrNetwork means the storage backend could not be reached or timed out
+	ErrNetwork = errors.New("network error")
+	// ErrInvalidImage means image data could not be decoded or encoded
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrConfig means the configuration is missing, invalid or inconsistent
+	ErrConfig = errors.New("configuration error")
+)
+
+// kindError tags an error with a category without changing its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+// Error returns the message of the wrapped error
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the category and the wrapped error
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Wrap tags err with the category kind, so errors.Is(err, kind) reports true.
+// It returns nil if err is nil.
+func Wrap(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
@@ -0,0 +1,141 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/h2non/bimg"
+)
+
+// ErrBudgetUnreachable is returned when an image cannot be encoded within a byte budget
+var ErrBudgetUnreachable = errors.New("size budget unreachable")
+
+// Limits of downscaling to fit a budget
+const (
+	maxDownscaleRounds = 8
+	minDownscaleSide   = 16
+)
+
+// Budget limits the encoded size of an image
+type Budget struct {
+	MaxBytes int64
+	// MinQuality is the lowest quality tried, the quality of the options is the highest
+	MinQuality int
+	// Downscale allows reducing the dimensions when the lowest quality does not fit
+	Downscale bool
+}
+
+// BudgetResult is an image encoded within a budget
+type BudgetResult struct {
+	Data    []byte
+	Quality int
+	Width   int
+	Height  int
+	// Downscaled reports whether the dimensions were reduced to fit
+	Downscaled bool
+}
+
+// ProcessWithin encodes the image at the highest quality, up to opts.Quality, whose output
+// fits the budget. Quality is found by binary search, so each image is encoded a few
+// times. If even the lowest quality is too large and downscaling is allowed, the image
+// is made smaller step by step until it fits.
+func (p *Processor) ProcessWithin(opts ProcessOptions, budget Budget) (BudgetResult, error) {
+	if opts.Format == bimg.UNKNOWN {
+		opts.Format = bimg.DetermineImageType(p.buffer)
+	}
+
+	data, quality, smallest, err := p.fitQuality(opts, budget)
+	if err != nil {
+		return BudgetResult{}, err
+	}
+	size, err := bimg.Size(smallest)
+	if err != nil {
+		return BudgetResult{}, fmt.Errorf("error getting processed image size: %w", err)
+	}
+	if data != nil {
+		return BudgetResult{Data: data, Quality: quality, Width: size.Width, Height: size.Height}, nil
+	}
+
+	width, height := size.Width, size.Height
+	if budget.Downscale {
+		scale := 1.0
+		for round := 0; round < maxDownscaleRounds; round++ {
+			// The encoded size shrinks roughly with the number of pixels
+			step := math.Sqrt(float64(budget.MaxBytes) / float64(len(smallest)))
+			scale *= math.Max(0.5, math.Min(0.9, step))
+			width = int(math.Round(float64(size.Width) * scale))
+			height = int(math.Round(float64(size.Height) * scale))
+			if width < minDownscaleSide || height < minDownscaleSide {
+				break
+			}
+
+			// Cropped images keep their box, others fit into the scaled size
+			scaledOpts := opts
+			scaledOpts.Resize = Resize{Width: width, Height: height, Mode: ResizeFit}
+			if opts.Resize.Mode == ResizeCrop {
+				scaledOpts.Resize.Mode, scaledOpts.Resize.Gravity = ResizeCrop, opts.Resize.Gravity
+			}
+
+			data, quality, smallest, err = p.fitQuality(scaledOpts, budget)
+			if err != nil {
+				return BudgetResult{}, err
+			}
+			if data != nil {
+				return BudgetResult{Data: data, Quality: quality, Width: width, Height: height, Downscaled: true}, nil
+			}
+		}
+	}
+
+	lowest := opts.Quality
+	if IsLossy(opts.Format) {
+		lowest = min(budget.MinQuality, opts.Quality)
+	}
+	return BudgetResult{}, fmt.Errorf("%w: %dx%d at quality %d is still %d bytes, over the budget of %d bytes",
+		ErrBudgetUnreachable, width, height, lowest, len(smallest), budget.MaxBytes)
+}
+
+// fitQuality encodes the image at the highest quality from budget.MinQuality to opts.Quality
+// that fits the budget. When no quality fits, data is nil. smallest is the output at the
+// lowest quality tried, used to estimate how much the image has to shrink.
+func (p *Processor) fitQuality(opts ProcessOptions, budget Budget) (data []byte, quality int, smallest []byte, err error) {
+	fits := func(data []byte) bool {
+		return int64(len(data)) <= budget.MaxBytes
+	}
+	encode := func(quality int) ([]byte, error) {
+		qualityOpts := opts
+		qualityOpts.Quality = quality
+		return p.Process(qualityOpts)
+	}
+
+	// Most images fit at the requested quality
+	data, err = encode(opts.Quality)
+	if err != nil || fits(data) {
+		return data, opts.Quality, data, err
+	}
+	if !IsLossy(opts.Format) || budget.MinQuality >= opts.Quality {
+		return nil, 0, data, nil
+	}
+
+	smallest, err = encode(budget.MinQuality)
+	if err != nil || !fits(smallest) {
+		return nil, 0, smallest, err
+	}
+
+	// Search the qualities between the lowest, which fits, and the highest, which does not
+	data, quality = smallest, budget.MinQuality
+	low, high := budget.MinQuality+1, opts.Quality-1
+	for low <= high {
+		mid := (low + high) / 2
+		encoded, err := encode(mid)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if fits(encoded) {
+			data, quality, low = encoded, mid, mid+1
+		} else {
+			high = mid - 1
+		}
+	}
+	return data, quality, smallest, nil
+}
@@ -0,0 +1,118 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// Format describes an output format that images can be encoded to
+type Format struct {
+	Name      string
+	Aliases   []string
+	Type      bimg.ImageType
+	Extension string
+	MimeType  string
+	// Lossy formats trade quality for size, the quality option has no effect on the others
+	Lossy bool
+}
+
+// formats is the registry of output formats, in the order they are offered to users.
+// JPEG XL is not listed because bimg has no image type for it, so it cannot be
+// selected even when libvips was built with libjxl.
+var formats = []Format{
+	{Name: "webp", Type: bimg.WEBP, Extension: "webp", MimeType: "image/webp", Lossy: true},
+	{Name: "avif", Type: bimg.AVIF, Extension: "avif", MimeType: "image/avif", Lossy: true},
+	{Name: "jpeg", Aliases: []string{"jpg"}, Type: bimg.JPEG, Extension: "jpeg", MimeType: "image/jpeg", Lossy: true},
+	{Name: "png", Type: bimg.PNG, Extension: "png", MimeType: "image/png"},
+	{Name: "heif", Aliases: []string{"heic"}, Type: bimg.HEIF, Extension: "heif", MimeType: "image/heif", Lossy: true},
+	{Name: "gif", Type: bimg.GIF, Extension: "gif", MimeType: "image/gif"},
+	{Name: "tiff", Aliases: []string{"tif"}, Type: bimg.TIFF, Extension: "tiff", MimeType: "image/tiff"},
+}
+
+// AutoFormat is the format name that picks the smallest of several candidate formats
+const AutoFormat = "auto"
+
+// DefaultAutoFormats lists the candidates of AutoFormat unless configured otherwise
+var DefaultAutoFormats = []string{"webp", "avif", "jpeg", "png"}
+
+// lookupFormat finds a registered format by name or alias
+func lookupFormat(name string) (Format, bool) {
+	name = strings.ToLower(name)
+	for _, format := range formats {
+		if format.Name == name {
+			return format, true
+		}
+		for _, alias := range format.Aliases {
+			if alias == name {
+				return format, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// formatForType finds the registered format for an image type
+func formatForType(t bimg.ImageType) (Format, bool) {
+	for _, format := range formats {
+		if format.Type == t {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// ParseFormat returns the image type for a format name such as "webp" or "jpg".
+// Formats that are unknown or that the installed libvips cannot encode are rejected.
+func ParseFormat(name string) (bimg.ImageType, error) {
+	format, ok := lookupFormat(name)
+	if !ok {
+		return bimg.UNKNOWN, fmt.Errorf("unsupported format: %s (supported: %s)", name, strings.Join(FormatNames(), ", "))
+	}
+	if !bimg.IsTypeSupportedSave(format.Type) {
+		return bimg.UNKNOWN, fmt.Errorf("format %s is not supported by the installed libvips", format.Name)
+	}
+	return format.Type, nil
+}
+
+// FormatNames returns the names and aliases of all registered formats, for help
+// texts and shell completion
+func FormatNames() []string {
+	var names []string
+	for _, format := range formats {
+		names = append(names, format.Name)
+		names = append(names, format.Aliases...)
+	}
+	return names
+}
+
+// Extension returns the file extension, without the dot, for an image type
+func Extension(t bimg.ImageType) string {
+	if format, ok := formatForType(t); ok {
+		return format.Extension
+	}
+	return strings.ToLower(bimg.ImageTypeName(t))
+}
+
+// MimeType returns the MIME type for an image type
+func MimeType(t bimg.ImageType) string {
+	if format, ok := formatForType(t); ok {
+		return format.MimeType
+	}
+	switch t {
+	case bimg.SVG:
+		return "image/svg+xml"
+	case bimg.PDF:
+		return "application/pdf"
+	case bimg.UNKNOWN:
+		return "application/octet-stream"
+	}
+	return "image/" + bimg.ImageTypeName(t)
+}
+
+// IsLossy reports whether the quality option affects the encoding of an image type
+func IsLossy(t bimg.ImageType) bool {
+	format, ok := formatForType(t)
+	return ok && format.Lossy
+}
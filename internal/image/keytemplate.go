@@ -0,0 +1,98 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyVars holds the values available to a key template
+type KeyVars struct {
+	// Name is the input file name without its extension
+	Name string
+	// Ext is the extension of the stored image, without the dot
+	Ext string
+	// Hash is the hex SHA-256 of the stored image data
+	Hash   string
+	Width  int
+	Height int
+	Time   time.Time
+}
+
+// keyPlaceholder matches placeholders such as {name}, {hash:8} and {date:2006/01}
+var keyPlaceholder = regexp.MustCompile(`\{([a-z]+)(?::([^}]*))?\}`)
+
+// HashData returns the hex SHA-256 of data as used by {hash}
+func HashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ExpandKeyTemplate builds an object key from a template. Supported placeholders are
+// {hash}, {hash:N} (first N characters), {name}, {ext}, {date} or {date:LAYOUT} with a
+// Go time layout, {w} and {h}.
+func ExpandKeyTemplate(template string, vars KeyVars) (string, error) {
+	var expandErr error
+	key := keyPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		match := keyPlaceholder.FindStringSubmatch(placeholder)
+		name, arg := match[1], match[2]
+
+		switch name {
+		case "hash":
+			if arg == "" {
+				return vars.Hash
+			}
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > sha256.Size*2 {
+				expandErr = fmt.Errorf("invalid hash length in %s (expected 1-%d)", placeholder, sha256.Size*2)
+				return ""
+			}
+			if n > len(vars.Hash) {
+				return vars.Hash
+			}
+			return vars.Hash[:n]
+		case "name":
+			return vars.Name
+		case "ext":
+			return vars.Ext
+		case "date":
+			if arg == "" {
+				arg = "2006/01/02"
+			}
+			return vars.Time.Format(arg)
+		case "w":
+			return strconv.Itoa(vars.Width)
+		case "h":
+			return strconv.Itoa(vars.Height)
+		default:
+			expandErr = fmt.Errorf("unknown placeholder in key template: %s", placeholder)
+			return ""
+		}
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("key template %q produces an empty key", template)
+	}
+	return key, nil
+}
+
+// ValidateKeyTemplate reports errors in a key template before any image is processed
+func ValidateKeyTemplate(template string) error {
+	_, err := ExpandKeyTemplate(template, KeyVars{
+		Name:   "name",
+		Ext:    "ext",
+		Hash:   strings.Repeat("0", sha256.Size*2),
+		Width:  1,
+		Height: 1,
+		Time:   time.Now(),
+	})
+	return err
+}
@@ -0,0 +1,97 @@
+package image
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandKeyTemplate(t *testing.T) {
+	vars := KeyVars{
+		Name:   "holiday",
+		Ext:    "webp",
+		Hash:   HashData([]byte("image")),
+		Width:  1600,
+		Height: 900,
+		Time:   time.Date(2026, 3, 7, 14, 5, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		template string
+		want     string
+		wantErr  string
+	}{
+		{template: "{name}.{ext}", want: "holiday.webp"},
+		{template: "images/{hash}.{ext}", want: "images/" + vars.Hash + ".webp"},
+		{template: "{hash:8}.{ext}", want: vars.Hash[:8] + ".webp"},
+		{template: "{hash:64}", want: vars.Hash},
+		{template: "{date}/{name}.{ext}", want: "2026/03/07/holiday.webp"},
+		{template: "{date:2006-01}/{name}", want: "2026-03/holiday"},
+		{template: "{name}-{w}x{h}.{ext}", want: "holiday-1600x900.webp"},
+		{template: "/{name}.{ext}", want: "holiday.webp"},
+		{template: "static/logo.png", want: "static/logo.png"},
+		{template: "{hash:0}", wantErr: "invalid hash length in {hash:0}"},
+		{template: "{hash:65}", wantErr: "invalid hash length in {hash:65}"},
+		{template: "{hash:x}", wantErr: "invalid hash length in {hash:x}"},
+		{template: "{size}.{ext}", wantErr: "unknown placeholder in key template: {size}"},
+		{template: "/", wantErr: "produces an empty key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ExpandKeyTemplate(tt.template, vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ExpandKeyTemplate(%q) = %q, %v, want error containing %q", tt.template, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandKeyTemplate(%q) error: %v", tt.template, err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandKeyTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandKeyTemplateShortHash(t *testing.T) {
+	got, err := ExpandKeyTemplate("{hash:16}", KeyVars{Hash: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "abc" {
+		t.Errorf("hash longer than the value = %q, want the whole value", got)
+	}
+}
+
+func TestValidateKeyTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{template: "{date:2006/01}/{hash:12}.{ext}", valid: true},
+		{template: "{name}-{w}w.{ext}", valid: true},
+		{template: "{nmae}.{ext}", valid: false},
+		{template: "{hash:100}", valid: false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateKeyTemplate(tt.template)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateKeyTemplate(%q) = %v, want valid %v", tt.template, err, tt.valid)
+		}
+	}
+}
+
+func TestHashData(t *testing.T) {
+	// SHA-256 of the empty input
+	const empty = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := HashData(nil); got != empty {
+		t.Errorf("HashData(nil) = %s, want %s", got, empty)
+	}
+	if HashData([]byte("a")) == HashData([]byte("b")) {
+		t.Errorf("different data has the same hash")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/h2non/bimg"
+
+	"github.com/mingeme/imgood/internal/errs"
 )
 
 // Processor handles image processing operations
@@ -15,16 +17,18 @@ type Processor struct {
 	buffer        []byte
 	width         int
 	height        int
+	// overlay is the last watermark rendered
+	overlay *overlay
 }
 
 // ProcessOptions contains options for image processing
 type ProcessOptions struct {
-	Quality       int
-	Width         int
-	Height        int
-	Format        bimg.ImageType
-	KeepMetadata  bool
-	NoRotate      bool
+	Quality      int
+	Resize       Resize
+	Format       bimg.ImageType
+	KeepMetadata bool
+	NoRotate     bool
+	Watermark    Watermark
 }
 
 // NewProcessor creates a new image processor from a file
@@ -35,13 +39,18 @@ func NewProcessor(filePath string) (*Processor, error) {
 		return nil, fmt.Errorf("error reading image: %w", err)
 	}
 
+	return NewProcessorFromBuffer(buffer)
+}
+
+// NewProcessorFromBuffer creates a new image processor from image data in memory
+func NewProcessorFromBuffer(buffer []byte) (*Processor, error) {
 	// Create image object
 	originalImage := bimg.NewImage(buffer)
 
 	// Get image size
 	size, err := originalImage.Size()
 	if err != nil {
-		return nil, fmt.Errorf("error getting image size: %w", err)
+		return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error getting image size: %w", err))
 	}
 
 	return &Processor{
@@ -59,6 +68,24 @@ func (p *Processor) GetOriginalInfo() (int, int, int, string) {
 	return p.width, p.height, len(p.buffer), originalFormat
 }
 
+// displaySize returns the size of the image after the rotation from its EXIF orientation,
+// unless rotation is disabled
+func (p *Processor) displaySize(noRotate bool) (int, int) {
+	if !noRotate {
+		// Orientations 5 to 8 turn the image by 90 or 270 degrees
+		if metadata, err := bimg.Metadata(p.buffer); err == nil && metadata.Orientation >= 5 {
+			return p.height, p.width
+		}
+	}
+	return p.width, p.height
+}
+
+// HasAlpha reports whether the image has an alpha channel, which formats like JPEG drop
+func (p *Processor) HasAlpha() bool {
+	metadata, err := bimg.Metadata(p.buffer)
+	return err == nil && metadata.Alpha
+}
+
 // GetOriginalBuffer returns the original image buffer
 func (p *Processor) GetOriginalBuffer() []byte {
 	return p.buffer
@@ -68,39 +95,67 @@ func (p *Processor) GetOriginalBuffer() []byte {
 func (p *Processor) Process(opts ProcessOptions) ([]byte, error) {
 	// Create options for processing
 	options := bimg.Options{
-		Quality: opts.Quality,
-		Type:    opts.Format,
-		NoProfile: !opts.KeepMetadata,      // Remove ICC profile unless KeepMetadata is true
-		StripMetadata: !opts.KeepMetadata,  // Strip all metadata unless KeepMetadata is true
-		NoAutoRotate: opts.NoRotate,        // Disable auto-rotation if NoRotate is true
+		Quality:       opts.Quality,
+		Type:          opts.Format,
+		NoProfile:     !opts.KeepMetadata, // Remove ICC profile unless KeepMetadata is true
+		StripMetadata: !opts.KeepMetadata, // Strip all metadata unless KeepMetadata is true
+		NoAutoRotate:  opts.NoRotate,      // Disable auto-rotation if NoRotate is true
 	}
 
-	// Set width and height if provided
-	if opts.Width > 0 {
-		options.Width = opts.Width
-	}
-	if opts.Height > 0 {
-		options.Height = opts.Height
+	// Resize relative to the image as displayed, which may be rotated
+	width, height := p.displaySize(opts.NoRotate)
+	opts.Resize.apply(&options, width, height)
+
+	// The watermark is drawn after resizing, relative to the output size
+	if !opts.Watermark.IsZero() {
+		if options.Width > 0 && options.Height > 0 {
+			width, height = options.Width, options.Height
+		}
+		watermark, err := p.watermarkOverlay(opts.Watermark, width, height)
+		if err != nil {
+			return nil, err
+		}
+		options.WatermarkImage = watermark
 	}
 
 	// Process the image
 	newImage, err := p.originalImage.Process(options)
 	if err != nil {
-		return nil, fmt.Errorf("error processing image: %w", err)
+		return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error processing image: %w", err))
 	}
 
 	return newImage, nil
 }
 
+// imageExtensions lists the file extensions treated as images when scanning directories
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+	".gif":  true,
+	".tif":  true,
+	".tiff": true,
+	".heic": true,
+	".heif": true,
+	".avif": true,
+	".svg":  true,
+}
+
+// IsImageFile reports whether the path has a known image file extension
+func IsImageFile(path string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
 // GetOutputFilename returns an appropriate filename for the processed image
-func GetOutputFilename(inputPath string, compress bool, format bimg.ImageType, useTimestamp bool) string {
+func GetOutputFilename(inputPath string, convert bool, format bimg.ImageType, useTimestamp bool) string {
 	// Determine the appropriate extension
 	var extension string
-	if !compress {
-		// Keep original extension if not compressing
+	if !convert {
+		// Keep original extension if not converting
 		extension = filepath.Ext(inputPath)
 	} else {
-		extension = "." + strings.ToLower(bimg.ImageTypeName(format))
+		extension = "." + Extension(format)
 	}
 
 	// Use timestamp or original filename as base
@@ -114,7 +169,7 @@ func GetOutputFilename(inputPath string, compress bool, format bimg.ImageType, u
 
 	baseName := filepath.Base(inputPath)
 	// Use original filename
-	if !compress {
+	if !convert {
 		return baseName
 	}
 
@@ -0,0 +1,213 @@
+package image
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// ResizeMode decides how an image is fitted into the requested size
+type ResizeMode string
+
+const (
+	// ResizeFit scales the image to fit within the size, keeping the aspect ratio
+	ResizeFit ResizeMode = "fit"
+	// ResizeFill scales the image to cover the size, keeping the aspect ratio
+	ResizeFill ResizeMode = "fill"
+	// ResizeCrop covers the size and crops the overflow at the gravity
+	ResizeCrop ResizeMode = "crop"
+)
+
+// Gravity is the part of the image kept when cropping
+type Gravity string
+
+const (
+	GravityCenter Gravity = "center"
+	GravityNorth  Gravity = "north"
+	GravitySouth  Gravity = "south"
+	GravityEast   Gravity = "east"
+	GravityWest   Gravity = "west"
+	// GravitySmart keeps the most interesting part, using the libvips attention strategy
+	GravitySmart Gravity = "smart"
+)
+
+// ResizeModes lists the supported resize modes
+var ResizeModes = []string{string(ResizeFit), string(ResizeFill), string(ResizeCrop)}
+
+// Gravities lists the supported crop gravities
+var Gravities = []string{string(GravityCenter), string(GravityNorth), string(GravitySouth), string(GravityEast), string(GravityWest), string(GravitySmart)}
+
+// Resize describes how an image is resized. A zero Resize keeps the original size.
+type Resize struct {
+	// Width and Height of the target size, 0 to derive it from the aspect ratio
+	Width  int
+	Height int
+	// Max limits the longest side instead of a target size
+	Max       int
+	Mode      ResizeMode
+	Gravity   Gravity
+	NoUpscale bool
+}
+
+// ParseResize parses a resize spec: "800x600" (fit within), "800x" or "x600" (one side),
+// "800x600^" (fill), or "max:2048" (limit the longest side, never upscaling).
+// The legacy form "800,600", where 0 keeps the aspect ratio, is also accepted.
+// An empty spec keeps the original size.
+func ParseResize(spec string) (Resize, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Resize{}, nil
+	}
+
+	if value, ok := strings.CutPrefix(spec, "max:"); ok {
+		max, err := strconv.Atoi(value)
+		if err != nil || max < 1 {
+			return Resize{}, fmt.Errorf("invalid resize spec: %s (expected max:PIXELS)", spec)
+		}
+		return Resize{Max: max, Mode: ResizeFit, NoUpscale: true}, nil
+	}
+
+	r := Resize{Mode: ResizeFit}
+	if value, ok := strings.CutSuffix(spec, "^"); ok {
+		spec, r.Mode = value, ResizeFill
+	}
+
+	separator := "x"
+	if strings.Contains(spec, ",") {
+		separator = ","
+	}
+	width, height, ok := strings.Cut(strings.ToLower(spec), separator)
+	if !ok {
+		return Resize{}, fmt.Errorf("invalid resize spec: %s (expected WxH, Wx, xH, WxH^ or max:N)", spec)
+	}
+
+	var err error
+	if r.Width, err = parseDimension(width); err != nil {
+		return Resize{}, fmt.Errorf("invalid resize width in %s: %w", spec, err)
+	}
+	if r.Height, err = parseDimension(height); err != nil {
+		return Resize{}, fmt.Errorf("invalid resize height in %s: %w", spec, err)
+	}
+	if r.Width == 0 && r.Height == 0 {
+		return Resize{}, fmt.Errorf("invalid resize spec: %s (width or height is required)", spec)
+	}
+	if r.Mode == ResizeFill && (r.Width == 0 || r.Height == 0) {
+		return Resize{}, fmt.Errorf("invalid resize spec: %s^ (fill needs both width and height)", spec)
+	}
+	return r, nil
+}
+
+// parseDimension parses one side of a resize spec, empty or 0 meaning automatic
+func parseDimension(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a number of pixels", value)
+	}
+	return n, nil
+}
+
+// ParseResizeMode parses a resize mode name
+func ParseResizeMode(name string) (ResizeMode, error) {
+	for _, mode := range ResizeModes {
+		if strings.EqualFold(name, mode) {
+			return ResizeMode(mode), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported resize mode: %s (expected %s)", name, strings.Join(ResizeModes, ", "))
+}
+
+// ParseGravity parses a crop gravity name, accepting "attention" for smart and "centre" for center
+func ParseGravity(name string) (Gravity, error) {
+	switch strings.ToLower(name) {
+	case "attention":
+		return GravitySmart, nil
+	case "centre":
+		return GravityCenter, nil
+	}
+	for _, gravity := range Gravities {
+		if strings.EqualFold(name, gravity) {
+			return Gravity(gravity), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported gravity: %s (expected %s)", name, strings.Join(Gravities, ", "))
+}
+
+// IsZero reports whether the resize keeps the original size
+func (r Resize) IsZero() bool {
+	return r.Width == 0 && r.Height == 0 && r.Max == 0
+}
+
+// apply sets the size options of a bimg resize for an image of width×height pixels,
+// as displayed after EXIF rotation
+func (r Resize) apply(options *bimg.Options, width, height int) {
+	if r.IsZero() || width == 0 || height == 0 {
+		return
+	}
+
+	targetWidth, targetHeight := r.Width, r.Height
+	if r.Max > 0 {
+		targetWidth, targetHeight = r.Max, r.Max
+	}
+
+	// Scale factors of each side, an automatic side follows the other
+	scaleX := float64(targetWidth) / float64(width)
+	scaleY := float64(targetHeight) / float64(height)
+	if targetWidth == 0 {
+		scaleX = scaleY
+	}
+	if targetHeight == 0 {
+		scaleY = scaleX
+	}
+
+	scale := math.Min(scaleX, scaleY)
+	if r.Mode == ResizeFill || r.Mode == ResizeCrop {
+		scale = math.Max(scaleX, scaleY)
+	}
+	if r.NoUpscale && scale > 1 {
+		scale = 1
+	}
+
+	if r.Mode == ResizeCrop && targetWidth > 0 && targetHeight > 0 {
+		// bimg covers the crop box and extracts it at the gravity. Without upscaling
+		// the box cannot be larger than the image.
+		options.Width, options.Height = targetWidth, targetHeight
+		if r.NoUpscale {
+			options.Width = min(targetWidth, width)
+			options.Height = min(targetHeight, height)
+		}
+		options.Crop = true
+		options.Enlarge = !r.NoUpscale
+		options.Gravity = r.Gravity.bimg()
+		return
+	}
+
+	// The aspect ratio is kept by the computed size, so it can be forced
+	options.Width = max(1, int(math.Round(float64(width)*scale)))
+	options.Height = max(1, int(math.Round(float64(height)*scale)))
+	options.Force = true
+}
+
+// bimg returns the bimg gravity
+func (g Gravity) bimg() bimg.Gravity {
+	switch g {
+	case GravityNorth:
+		return bimg.GravityNorth
+	case GravitySouth:
+		return bimg.GravitySouth
+	case GravityEast:
+		return bimg.GravityEast
+	case GravityWest:
+		return bimg.GravityWest
+	case GravitySmart:
+		return bimg.GravitySmart
+	default:
+		return bimg.GravityCentre
+	}
+}
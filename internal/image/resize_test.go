@@ -0,0 +1,194 @@
+package image
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestParseResize(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Resize
+		wantErr string
+	}{
+		{spec: "", want: Resize{}},
+		{spec: "  ", want: Resize{}},
+		{spec: "800x600", want: Resize{Width: 800, Height: 600, Mode: ResizeFit}},
+		{spec: "800X600", want: Resize{Width: 800, Height: 600, Mode: ResizeFit}},
+		{spec: "800x", want: Resize{Width: 800, Mode: ResizeFit}},
+		{spec: "x600", want: Resize{Height: 600, Mode: ResizeFit}},
+		{spec: "800x600^", want: Resize{Width: 800, Height: 600, Mode: ResizeFill}},
+		{spec: "max:2048", want: Resize{Max: 2048, Mode: ResizeFit, NoUpscale: true}},
+		{spec: "800,600", want: Resize{Width: 800, Height: 600, Mode: ResizeFit}},
+		{spec: "800,0", want: Resize{Width: 800, Mode: ResizeFit}},
+		{spec: "0,600", want: Resize{Height: 600, Mode: ResizeFit}},
+		{spec: " 800 x 600 ", want: Resize{Width: 800, Height: 600, Mode: ResizeFit}},
+		{spec: "800", wantErr: "expected WxH"},
+		{spec: "x", wantErr: "width or height is required"},
+		{spec: "0x0", wantErr: "width or height is required"},
+		{spec: "800x^", wantErr: "fill needs both width and height"},
+		{spec: "abcx600", wantErr: "invalid resize width"},
+		{spec: "800x-1", wantErr: "invalid resize height"},
+		{spec: "max:0", wantErr: "expected max:PIXELS"},
+		{spec: "max:big", wantErr: "expected max:PIXELS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseResize(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseResize(%q) = %+v, %v, want error containing %q", tt.spec, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseResize(%q) error: %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseResize(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResizeMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ResizeMode
+		wantErr bool
+	}{
+		{name: "fit", want: ResizeFit},
+		{name: "FILL", want: ResizeFill},
+		{name: "crop", want: ResizeCrop},
+		{name: "stretch", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResizeMode(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseResizeMode(%q) = %q, %v, want %q (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseGravity(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Gravity
+		wantErr bool
+	}{
+		{name: "center", want: GravityCenter},
+		{name: "centre", want: GravityCenter},
+		{name: "North", want: GravityNorth},
+		{name: "smart", want: GravitySmart},
+		{name: "attention", want: GravitySmart},
+		{name: "northwest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseGravity(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseGravity(%q) = %q, %v, want %q (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResizeApply(t *testing.T) {
+	tests := []struct {
+		name          string
+		resize        Resize
+		width, height int
+		want          bimg.Options
+	}{
+		{
+			name:   "zero keeps the size",
+			resize: Resize{},
+			width:  4000, height: 3000,
+			want: bimg.Options{},
+		},
+		{
+			name:   "fit within a box",
+			resize: Resize{Width: 800, Height: 800, Mode: ResizeFit},
+			width:  4000, height: 3000,
+			want: bimg.Options{Width: 800, Height: 600, Force: true},
+		},
+		{
+			name:   "fit by width",
+			resize: Resize{Width: 1000, Mode: ResizeFit},
+			width:  4000, height: 3000,
+			want: bimg.Options{Width: 1000, Height: 750, Force: true},
+		},
+		{
+			name:   "fit by height",
+			resize: Resize{Height: 300, Mode: ResizeFit},
+			width:  4000, height: 3000,
+			want: bimg.Options{Width: 400, Height: 300, Force: true},
+		},
+		{
+			name:   "fill covers the box",
+			resize: Resize{Width: 800, Height: 800, Mode: ResizeFill},
+			width:  4000, height: 3000,
+			want: bimg.Options{Width: 1067, Height: 800, Force: true},
+		},
+		{
+			name:   "fit enlarges small images",
+			resize: Resize{Width: 800, Mode: ResizeFit},
+			width:  400, height: 200,
+			want: bimg.Options{Width: 800, Height: 400, Force: true},
+		},
+		{
+			name:   "no upscale keeps small images",
+			resize: Resize{Width: 800, Mode: ResizeFit, NoUpscale: true},
+			width:  400, height: 200,
+			want: bimg.Options{Width: 400, Height: 200, Force: true},
+		},
+		{
+			name:   "max limits the longest side",
+			resize: Resize{Max: 2048, Mode: ResizeFit, NoUpscale: true},
+			width:  3000, height: 4000,
+			want: bimg.Options{Width: 1536, Height: 2048, Force: true},
+		},
+		{
+			name:   "crop at the gravity",
+			resize: Resize{Width: 256, Height: 256, Mode: ResizeCrop, Gravity: GravitySmart},
+			width:  4000, height: 3000,
+			want: bimg.Options{Width: 256, Height: 256, Crop: true, Enlarge: true, Gravity: bimg.GravitySmart},
+		},
+		{
+			name:   "crop without upscaling shrinks the box",
+			resize: Resize{Width: 800, Height: 800, Mode: ResizeCrop, NoUpscale: true},
+			width:  600, height: 400,
+			want: bimg.Options{Width: 600, Height: 400, Crop: true, Gravity: bimg.GravityCentre},
+		},
+		{
+			name:   "crop with one side fits",
+			resize: Resize{Width: 800, Mode: ResizeCrop},
+			width:  4000, height: 3000,
+			want: bimg.Options{Width: 800, Height: 600, Force: true},
+		},
+		{
+			name:   "tiny results keep a pixel",
+			resize: Resize{Width: 1, Mode: ResizeFit},
+			width:  1000, height: 10,
+			want: bimg.Options{Width: 1, Height: 1, Force: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bimg.Options
+			tt.resize.apply(&got, tt.width, tt.height)
+			if got.Width != tt.want.Width || got.Height != tt.want.Height || got.Force != tt.want.Force ||
+				got.Crop != tt.want.Crop || got.Enlarge != tt.want.Enlarge || got.Gravity != tt.want.Gravity {
+				t.Errorf("apply(%+v) on %dx%d = %dx%d force=%v crop=%v enlarge=%v gravity=%v, want %dx%d force=%v crop=%v enlarge=%v gravity=%v",
+					tt.resize, tt.width, tt.height,
+					got.Width, got.Height, got.Force, got.Crop, got.Enlarge, got.Gravity,
+					tt.want.Width, tt.want.Height, tt.want.Force, tt.want.Crop, tt.want.Enlarge, tt.want.Gravity)
+			}
+		})
+	}
+}
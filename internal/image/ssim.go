@@ -0,0 +1,197 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	goimage "image"
+	"image/color"
+	"image/png"
+
+	"github.com/h2non/bimg"
+
+	"github.com/mingeme/imgood/internal/errs"
+)
+
+// Window of the SSIM comparison, moved by half its size
+const (
+	ssimWindow = 8
+	ssimStep   = ssimWindow / 2
+)
+
+// SSIM stabilizing constants for 8-bit values
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// SimilarityResult is an image encoded at the lowest quality meeting a similarity target
+type SimilarityResult struct {
+	Data    []byte
+	Quality int
+	// SSIM of the encoded image compared to the original
+	SSIM float64
+	// Reached reports whether the target was met. Otherwise the image is encoded at the
+	// highest quality.
+	Reached bool
+}
+
+// ProcessSimilar encodes the image at the lowest quality, from minQuality to 100, whose
+// SSIM compared to the original is at least target. The original is resized like the
+// encoded image, and both are compared on their luma. Quality is found by binary search,
+// assuming the SSIM grows with the quality.
+func (p *Processor) ProcessSimilar(opts ProcessOptions, target float64, minQuality int) (SimilarityResult, error) {
+	if opts.Format == bimg.UNKNOWN {
+		opts.Format = bimg.DetermineImageType(p.buffer)
+	}
+
+	// The reference is the original at the output size, encoded without loss
+	referenceOpts := opts
+	referenceOpts.Format = bimg.PNG
+	referenceData, err := p.Process(referenceOpts)
+	if err != nil {
+		return SimilarityResult{}, err
+	}
+	reference, err := decodeLuma(referenceData)
+	if err != nil {
+		return SimilarityResult{}, err
+	}
+
+	tried := make(map[int]SimilarityResult)
+	encode := func(quality int) (SimilarityResult, error) {
+		if result, ok := tried[quality]; ok {
+			return result, nil
+		}
+		qualityOpts := opts
+		qualityOpts.Quality = quality
+		data, err := p.Process(qualityOpts)
+		if err != nil {
+			return SimilarityResult{}, err
+		}
+		candidate, err := decodeLuma(data)
+		if err != nil {
+			return SimilarityResult{}, err
+		}
+		score, err := ssim(reference, candidate)
+		if err != nil {
+			return SimilarityResult{}, err
+		}
+		result := SimilarityResult{Data: data, Quality: quality, SSIM: score, Reached: score >= target}
+		tried[quality] = result
+		return result, nil
+	}
+
+	// The quality has no effect on lossless formats
+	if !IsLossy(opts.Format) {
+		return encode(opts.Quality)
+	}
+
+	var best SimilarityResult
+	low, high := max(1, minQuality), 100
+	for low <= high {
+		mid := (low + high) / 2
+		result, err := encode(mid)
+		if err != nil {
+			return SimilarityResult{}, err
+		}
+		if result.Reached {
+			best, high = result, mid-1
+		} else {
+			low = mid + 1
+		}
+	}
+	if best.Data == nil {
+		return encode(100)
+	}
+	return best, nil
+}
+
+// lumaImage is the 8-bit luma of an image
+type lumaImage struct {
+	pix    []uint8
+	width  int
+	height int
+}
+
+// decodeLuma decodes encoded image data into its luma. libvips converts the data to an
+// 8-bit grayscale PNG, which the standard library can decode whatever the source format.
+func decodeLuma(data []byte) (lumaImage, error) {
+	gray, err := bimg.NewImage(data).Process(bimg.Options{
+		Type:           bimg.PNG,
+		Interpretation: bimg.InterpretationBW,
+		Compression:    1,
+		NoAutoRotate:   true,
+	})
+	if err != nil {
+		return lumaImage{}, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error decoding image for comparison: %w", err))
+	}
+	decoded, err := png.Decode(bytes.NewReader(gray))
+	if err != nil {
+		return lumaImage{}, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error decoding image for comparison: %w", err))
+	}
+
+	bounds := decoded.Bounds()
+	luma := lumaImage{pix: make([]uint8, bounds.Dx()*bounds.Dy()), width: bounds.Dx(), height: bounds.Dy()}
+	if g, ok := decoded.(*goimage.Gray); ok {
+		for y := 0; y < luma.height; y++ {
+			copy(luma.pix[y*luma.width:(y+1)*luma.width], g.Pix[y*g.Stride:])
+		}
+		return luma, nil
+	}
+
+	// Images with transparency or 16-bit samples are decoded into other types
+	for y := 0; y < luma.height; y++ {
+		for x := 0; x < luma.width; x++ {
+			luma.pix[y*luma.width+x] = color.GrayModel.Convert(decoded.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return luma, nil
+}
+
+// ssim returns the mean structural similarity of two images of the same size, 1 for
+// identical images, over square windows that overlap by half
+func ssim(a, b lumaImage) (float64, error) {
+	if a.width != b.width || a.height != b.height {
+		return 0, fmt.Errorf("cannot compare images of different sizes: %dx%d and %dx%d", a.width, a.height, b.width, b.height)
+	}
+
+	// Images smaller than a window are compared as a whole
+	windowWidth, windowHeight := min(ssimWindow, a.width), min(ssimWindow, a.height)
+
+	var total float64
+	var windows int
+	for y := 0; y+windowHeight <= a.height; y += ssimStep {
+		for x := 0; x+windowWidth <= a.width; x += ssimStep {
+			total += windowSSIM(a, b, x, y, windowWidth, windowHeight)
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1, nil
+	}
+	return total / float64(windows), nil
+}
+
+// windowSSIM returns the structural similarity of one window of two images
+func windowSSIM(a, b lumaImage, x0, y0, width, height int) float64 {
+	var sumA, sumB, sumAA, sumBB, sumAB float64
+	for y := y0; y < y0+height; y++ {
+		row := y * a.width
+		for x := x0; x < x0+width; x++ {
+			va, vb := float64(a.pix[row+x]), float64(b.pix[row+x])
+			sumA += va
+			sumB += vb
+			sumAA += va * va
+			sumBB += vb * vb
+			sumAB += va * vb
+		}
+	}
+
+	n := float64(width * height)
+	meanA, meanB := sumA/n, sumB/n
+	varianceA := sumAA/n - meanA*meanA
+	varianceB := sumBB/n - meanB*meanB
+	covariance := sumAB/n - meanA*meanB
+
+	return ((2*meanA*meanB + ssimC1) * (2*covariance + ssimC2)) /
+		((meanA*meanA + meanB*meanB + ssimC1) * (varianceA + varianceB + ssimC2))
+}
@@ -0,0 +1,142 @@
+package image
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+
+	"github.com/mingeme/imgood/internal/errs"
+)
+
+// DefaultVariantName is the default naming template for responsive variants
+const DefaultVariantName = "{name}-{w}w.{ext}"
+
+// Variant is one width of a responsive image set
+type Variant struct {
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// SrcsetEntry is an uploaded variant referenced from an HTML snippet
+type SrcsetEntry struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// ParseWidths parses a comma-separated list of widths such as "320,640,1280"
+// and returns them sorted in ascending order without duplicates
+func ParseWidths(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	var widths []int
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		width, err := strconv.Atoi(part)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid variant width: %s", part)
+		}
+		if !seen[width] {
+			seen[width] = true
+			widths = append(widths, width)
+		}
+	}
+
+	if len(widths) == 0 {
+		return nil, fmt.Errorf("no variant widths given")
+	}
+
+	sort.Ints(widths)
+	return widths, nil
+}
+
+// ProcessVariants encodes the image once per width, keeping the aspect ratio.
+// Widths larger than the original, as displayed after auto-rotation, are skipped to
+// avoid upscaling; if every width is larger, a single variant at the original width is
+// returned.
+func (p *Processor) ProcessVariants(opts ProcessOptions, widths []int) ([]Variant, error) {
+	originalWidth, _ := p.displaySize(opts.NoRotate)
+
+	var targets []int
+	for _, width := range widths {
+		if width <= originalWidth {
+			targets = append(targets, width)
+		}
+	}
+	if len(targets) == 0 {
+		targets = []int{originalWidth}
+	}
+
+	variants := make([]Variant, 0, len(targets))
+	for _, width := range targets {
+		variantOpts := opts
+		variantOpts.Resize = Resize{Width: width, Mode: ResizeFit}
+
+		data, err := p.Process(variantOpts)
+		if err != nil {
+			return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error creating %dw variant: %w", width, err))
+		}
+
+		size, err := bimg.Size(data)
+		if err != nil {
+			return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error getting %dw variant size: %w", width, err))
+		}
+
+		variants = append(variants, Variant{
+			Width:  size.Width,
+			Height: size.Height,
+			Data:   data,
+		})
+	}
+
+	return variants, nil
+}
+
+// VariantKey expands a naming template. {name} is the key without its extension,
+// {w} the variant width and {ext} the extension of the encoded format.
+func VariantKey(template, name string, width int, ext string) string {
+	return strings.NewReplacer(
+		"{name}", name,
+		"{w}", strconv.Itoa(width),
+		"{ext}", ext,
+	).Replace(template)
+}
+
+// ImgSnippet returns an <img> tag with a srcset covering all entries.
+// The largest entry is used as src and for the intrinsic size.
+func ImgSnippet(entries []SrcsetEntry, sizes string) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	largest := entries[len(entries)-1]
+
+	return fmt.Sprintf(`<img src="%s" srcset="%s" sizes="%s" width="%d" height="%d" alt="">`,
+		html.EscapeString(largest.URL), srcset(entries), html.EscapeString(sizes), largest.Width, largest.Height)
+}
+
+// PictureSnippet returns a <picture> element with a typed <source> and an <img> fallback
+func PictureSnippet(entries []SrcsetEntry, sizes string, format bimg.ImageType) string {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("<picture>\n  <source type=\"%s\" srcset=\"%s\" sizes=\"%s\">\n  %s\n</picture>",
+		MimeType(format), srcset(entries), html.EscapeString(sizes), ImgSnippet(entries, sizes))
+}
+
+// srcset builds the value of a srcset attribute
+func srcset(entries []SrcsetEntry) string {
+	candidates := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		candidates = append(candidates, fmt.Sprintf("%s %dw", html.EscapeString(entry.URL), entry.Width))
+	}
+	return strings.Join(candidates, ", ")
+}
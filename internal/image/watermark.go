@@ -0,0 +1,271 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	goimage "image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+
+	"github.com/mingeme/imgood/internal/errs"
+)
+
+// Position is the corner, edge or center of the image where a watermark is placed
+type Position string
+
+const (
+	PositionNorthWest Position = "northwest"
+	PositionNorth     Position = "north"
+	PositionNorthEast Position = "northeast"
+	PositionWest      Position = "west"
+	PositionCenter    Position = "center"
+	PositionEast      Position = "east"
+	PositionSouthWest Position = "southwest"
+	PositionSouth     Position = "south"
+	PositionSouthEast Position = "southeast"
+)
+
+// Positions lists the supported watermark positions
+var Positions = []string{
+	string(PositionNorthWest), string(PositionNorth), string(PositionNorthEast),
+	string(PositionWest), string(PositionCenter), string(PositionEast),
+	string(PositionSouthWest), string(PositionSouth), string(PositionSouthEast),
+}
+
+// textOffset is where bimg draws watermark text on its canvas
+const textOffset = 100
+
+// Watermark is text, an image such as a logo, or both, drawn over processed images.
+// With both, the image is placed above the text and the two are positioned together.
+type Watermark struct {
+	Text string
+	// Font is a Pango font description such as "sans" or "serif bold"
+	Font string
+	// Size is the height of the text in pixels, 0 for 1/30 of the image width
+	Size  int
+	Color bimg.Color
+
+	// Image is the encoded overlay image, usually a PNG with transparency
+	Image []byte
+	// Scale is the width of the overlay image relative to the width of the processed image
+	Scale float64
+
+	// Opacity from 0 (invisible) to 1 (opaque)
+	Opacity  float64
+	Position Position
+	// Margin is the distance in pixels to the edges of the image
+	Margin int
+}
+
+// IsZero reports whether the watermark draws nothing
+func (w Watermark) IsZero() bool {
+	return w.Text == "" && len(w.Image) == 0
+}
+
+// overlay is a watermark rendered for one image size
+type overlay struct {
+	width  int
+	height int
+	image  bimg.WatermarkImage
+}
+
+// ParsePosition parses a watermark position name, accepting "centre" for center
+func ParsePosition(name string) (Position, error) {
+	name = strings.ToLower(strings.ReplaceAll(name, "-", ""))
+	if name == "centre" {
+		return PositionCenter, nil
+	}
+	for _, position := range Positions {
+		if name == position {
+			return Position(position), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported watermark position: %s (expected %s)", name, strings.Join(Positions, ", "))
+}
+
+// ParseColor parses a color given as "#rrggbb", "#rgb", "white" or "black"
+func ParseColor(value string) (bimg.Color, error) {
+	switch strings.ToLower(value) {
+	case "white":
+		return bimg.Color{R: 255, G: 255, B: 255}, nil
+	case "black":
+		return bimg.Color{}, nil
+	}
+
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return bimg.Color{}, fmt.Errorf("invalid color: %s (expected #rrggbb, white or black)", value)
+	}
+	return bimg.Color{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb)}, nil
+}
+
+// watermarkOverlay returns the watermark rendered for an image of width×height pixels.
+// Searching the quality processes an image several times at the same size, so the
+// last overlay is kept.
+func (p *Processor) watermarkOverlay(w Watermark, width, height int) (bimg.WatermarkImage, error) {
+	if p.overlay != nil && p.overlay.width == width && p.overlay.height == height {
+		return p.overlay.image, nil
+	}
+
+	var parts []goimage.Image
+	if len(w.Image) > 0 {
+		logo, err := scaleWatermarkImage(w.Image, max(1, int(math.Round(w.Scale*float64(width)))))
+		if err != nil {
+			return bimg.WatermarkImage{}, err
+		}
+		parts = append(parts, logo)
+	}
+	if w.Text != "" {
+		text, err := renderWatermarkText(w, width, height)
+		if err != nil {
+			return bimg.WatermarkImage{}, err
+		}
+		parts = append(parts, text)
+	}
+
+	// Stack the parts, centered on each other, with a gap of half a line of text
+	gap := watermarkTextSize(w, width) / 2
+	var groupWidth, groupHeight int
+	for i, part := range parts {
+		groupWidth = max(groupWidth, part.Bounds().Dx())
+		groupHeight += part.Bounds().Dy()
+		if i > 0 {
+			groupHeight += gap
+		}
+	}
+
+	group := goimage.NewNRGBA(goimage.Rect(0, 0, groupWidth, groupHeight))
+	opacity := &goimage.Uniform{C: color.Alpha{A: uint8(math.Round(min(max(w.Opacity, 0), 1) * 255))}}
+	y := 0
+	for _, part := range parts {
+		bounds := part.Bounds()
+		x := (groupWidth - bounds.Dx()) / 2
+		draw.DrawMask(group, goimage.Rect(x, y, x+bounds.Dx(), y+bounds.Dy()), part, bounds.Min, opacity, goimage.Point{}, draw.Over)
+		y += bounds.Dy() + gap
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, group); err != nil {
+		return bimg.WatermarkImage{}, fmt.Errorf("error encoding watermark: %w", err)
+	}
+
+	left, top := w.place(width, height, groupWidth, groupHeight)
+	watermark := bimg.WatermarkImage{Left: left, Top: top, Buf: buf.Bytes(), Opacity: 1}
+	p.overlay = &overlay{width: width, height: height, image: watermark}
+	return watermark, nil
+}
+
+// place returns the top-left corner of a watermark of the given size on the image
+func (w Watermark) place(width, height, watermarkWidth, watermarkHeight int) (int, int) {
+	left, top := (width-watermarkWidth)/2, (height-watermarkHeight)/2
+	switch w.Position {
+	case PositionNorthWest, PositionWest, PositionSouthWest:
+		left = w.Margin
+	case PositionNorthEast, PositionEast, PositionSouthEast, "":
+		left = width - watermarkWidth - w.Margin
+	}
+	switch w.Position {
+	case PositionNorthWest, PositionNorth, PositionNorthEast:
+		top = w.Margin
+	case PositionSouthWest, PositionSouth, PositionSouthEast, "":
+		top = height - watermarkHeight - w.Margin
+	}
+	return max(0, left), max(0, top)
+}
+
+// watermarkTextSize returns the height of the watermark text in pixels
+func watermarkTextSize(w Watermark, width int) int {
+	if w.Size > 0 {
+		return w.Size
+	}
+	return max(12, width/30)
+}
+
+// scaleWatermarkImage decodes the watermark image resized to width pixels
+func scaleWatermarkImage(data []byte, width int) (goimage.Image, error) {
+	scaled, err := bimg.Resize(data, bimg.Options{Width: width, Type: bimg.PNG})
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error scaling watermark image: %w", err))
+	}
+	decoded, err := png.Decode(bytes.NewReader(scaled))
+	if err != nil {
+		return nil, errs.Wrap(errs.ErrInvalidImage, fmt.Errorf("error decoding watermark image: %w", err))
+	}
+	return decoded, nil
+}
+
+// renderWatermarkText renders the watermark text in its color for an image of width×height
+// pixels, wrapping lines within the margins. libvips draws the text with Pango onto a
+// black canvas in white, which gives the coverage of each pixel, and the text is then
+// cut out of the canvas.
+func renderWatermarkText(w Watermark, width, height int) (goimage.Image, error) {
+	size := watermarkTextSize(w, width)
+	wrap := max(size, width-2*w.Margin)
+
+	// The canvas must be larger than the text, which bimg draws at an offset. Text
+	// taller than the image is cut off.
+	canvas := goimage.NewGray(goimage.Rect(0, 0, wrap+2*textOffset, height+2*textOffset))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("error rendering watermark text: %w", err)
+	}
+
+	font := w.Font
+	if font == "" {
+		font = "sans"
+	}
+	rendered, err := bimg.NewImage(buf.Bytes()).Process(bimg.Options{
+		Type: bimg.PNG,
+		Watermark: bimg.Watermark{
+			Text: w.Text,
+			// Pango sizes are in points, which are pixels at 72 DPI
+			Font:        fmt.Sprintf("%s %d", font, size),
+			DPI:         72,
+			Width:       wrap,
+			Margin:      textOffset,
+			Opacity:     1,
+			NoReplicate: true,
+			Background:  bimg.Color{R: 255, G: 255, B: 255},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error rendering watermark text: %w", err)
+	}
+	coverage, err := decodeLuma(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the bounding box of the drawn pixels
+	var bounds goimage.Rectangle
+	for y := 0; y < coverage.height; y++ {
+		for x := 0; x < coverage.width; x++ {
+			if coverage.pix[y*coverage.width+x] > 0 {
+				bounds = bounds.Union(goimage.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if bounds.Empty() {
+		return nil, fmt.Errorf("watermark text %q rendered no pixels, check the font", w.Text)
+	}
+
+	// Paint the text color through the coverage
+	text := goimage.NewNRGBA(goimage.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			alpha := coverage.pix[(bounds.Min.Y+y)*coverage.width+bounds.Min.X+x]
+			text.SetNRGBA(x, y, color.NRGBA{R: w.Color.R, G: w.Color.G, B: w.Color.B, A: alpha})
+		}
+	}
+	return text, nil
+}
@@ -0,0 +1,80 @@
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Run processes n items with up to jobs concurrent workers.
+//
+// report is called from the calling goroutine for every finished item in
+// input order, so output stays ordered even when items finish out of order.
+// Once ctx is cancelled no new items are started; items already running are
+// allowed to finish and are still reported, while items that were never started
+// are skipped. Run returns the number of items that were reported.
+func Run[T any](ctx context.Context, n, jobs int, work func(ctx context.Context, i int) T, report func(i int, result T)) int {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > n {
+		jobs = n
+	}
+
+	results := make([]T, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// Feed item indexes to the workers until all are queued or ctx is cancelled
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := 0; i < n; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case queue <- i:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				results[i] = work(ctx, i)
+				close(done[i])
+			}
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	reported := 0
+	for i := 0; i < n; i++ {
+		select {
+		case <-done[i]:
+		case <-finished:
+			// All workers have exited, so an item that is not done was never started
+			select {
+			case <-done[i]:
+			default:
+				continue
+			}
+		}
+		report(i, results[i])
+		reported++
+	}
+
+	return reported
+}
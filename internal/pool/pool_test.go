@@ -0,0 +1,136 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunReportsInOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		jobs int
+	}{
+		{name: "no items", n: 0, jobs: 4},
+		{name: "one worker", n: 10, jobs: 1},
+		{name: "several workers", n: 50, jobs: 4},
+		{name: "more workers than items", n: 3, jobs: 16},
+		{name: "zero jobs runs one worker", n: 5, jobs: 0},
+		{name: "negative jobs runs one worker", n: 5, jobs: -2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order []int
+			reported := Run(context.Background(), tt.n, tt.jobs,
+				func(ctx context.Context, i int) int {
+					// Later items finish first to shuffle completion order
+					time.Sleep(time.Duration(tt.n-i) * 100 * time.Microsecond)
+					return i * i
+				},
+				func(i int, result int) {
+					if result != i*i {
+						t.Errorf("item %d reported result %d, want %d", i, result, i*i)
+					}
+					order = append(order, i)
+				})
+
+			if reported != tt.n {
+				t.Errorf("Run reported %d items, want %d", reported, tt.n)
+			}
+			for i, got := range order {
+				if got != i {
+					t.Fatalf("report order = %v, want ascending", order)
+				}
+			}
+		})
+	}
+}
+
+func TestRunLimitsConcurrency(t *testing.T) {
+	tests := []struct {
+		n    int
+		jobs int
+		want int
+	}{
+		{n: 20, jobs: 1, want: 1},
+		{n: 20, jobs: 3, want: 3},
+		{n: 2, jobs: 8, want: 2},
+	}
+
+	for _, tt := range tests {
+		var running, peak atomic.Int32
+		Run(context.Background(), tt.n, tt.jobs,
+			func(ctx context.Context, i int) struct{} {
+				current := running.Add(1)
+				for {
+					old := peak.Load()
+					if current <= old || peak.CompareAndSwap(old, current) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				running.Add(-1)
+				return struct{}{}
+			},
+			func(i int, result struct{}) {})
+
+		if got := int(peak.Load()); got > tt.want {
+			t.Errorf("n=%d jobs=%d: %d items ran concurrently, want at most %d", tt.n, tt.jobs, got, tt.want)
+		}
+	}
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	const n, cancelAt = 100, 5
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	started := make(map[int]bool)
+	var order []int
+	reported := Run(ctx, n, 2,
+		func(ctx context.Context, i int) int {
+			mu.Lock()
+			started[i] = true
+			mu.Unlock()
+			if i == cancelAt {
+				cancel()
+			}
+			return i
+		},
+		func(i int, result int) {
+			order = append(order, i)
+		})
+
+	if reported != len(order) {
+		t.Errorf("Run returned %d, but %d items were reported", reported, len(order))
+	}
+	if reported == n {
+		t.Errorf("reported all %d items after cancellation", n)
+	}
+	if len(order) == 0 || order[len(order)-1] < cancelAt {
+		t.Errorf("reported %v, want the item that cancelled the run", order)
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i] <= order[i-1] {
+			t.Fatalf("report order = %v, want ascending", order)
+		}
+	}
+
+	// Exactly the started items are reported, even those after a skipped item
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != len(started) {
+		t.Errorf("reported %v, but %d items were started", order, len(started))
+	}
+	for _, i := range order {
+		if !started[i] {
+			t.Errorf("item %d was reported without being started", i)
+		}
+	}
+}
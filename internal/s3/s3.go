@@ -3,16 +3,41 @@ package s3
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 
 	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/errs"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+const (
+	// maxListPageSize is the maximum number of keys S3 returns per ListObjectsV2 call
+	maxListPageSize = 1000
+	// maxDeleteBatchSize is the maximum number of keys S3 accepts per DeleteObjects call
+	maxDeleteBatchSize = 1000
+	// maxCopyObjectSize is the largest object CopyObject can copy in a single request
+	maxCopyObjectSize = 5 << 30
+	// copyPartSize is the part size used for multipart copies of larger objects
+	copyPartSize = 512 << 20
+	// maxPresignExpiry is the longest validity of a SigV4 presigned URL
+	maxPresignExpiry = 7 * 24 * time.Hour
 )
 
 // Client represents an S3 client
@@ -22,14 +47,20 @@ type Client struct {
 }
 
 // NewClient creates a new S3 client with the provided configuration
-func NewClient(cfg config.S3Config) (*Client, error) {
+func NewClient(ctx context.Context, cfg config.S3Config) (*Client, error) {
 	// Validate required configuration
 	if cfg.Bucket == "" {
 		return nil, fmt.Errorf("S3 bucket name is required")
 	}
+	if cfg.MultipartThreshold <= 0 {
+		return nil, fmt.Errorf("multipart_threshold must be greater than 0")
+	}
+	if cfg.PartSize < manager.MinUploadPartSize {
+		return nil, fmt.Errorf("part_size must be at least 5MB")
+	}
 
 	// Configure AWS
-	awsCfg, err := configureAWS(cfg.Region, cfg.AccessKey, cfg.SecretKey)
+	awsCfg, err := configureAWS(ctx, cfg.Region, cfg.AccessKey, cfg.SecretKey)
 	if err != nil {
 		return nil, fmt.Errorf("error configuring AWS: %w", err)
 	}
@@ -39,6 +70,7 @@ func NewClient(cfg config.S3Config) (*Client, error) {
 		if cfg.Endpoint != "" {
 			o.BaseEndpoint = aws.String(cfg.Endpoint)
 		}
+		o.UsePathStyle = cfg.PathStyle
 	})
 
 	return &Client{
@@ -47,88 +79,602 @@ func NewClient(cfg config.S3Config) (*Client, error) {
 	}, nil
 }
 
-// UploadFile uploads a file to S3
-func (c *Client) UploadFile(key string, data []byte) error {
-	ctx := context.Background()
-	_, err := c.s3Client.PutObject(ctx, &s3.PutObjectInput{
+var (
+	_ storage.Storage       = (*Client)(nil)
+	_ storage.Copier        = (*Client)(nil)
+	_ storage.BatchDeleter  = (*Client)(nil)
+	_ storage.Validator     = (*Client)(nil)
+	_ storage.Presigner     = (*Client)(nil)
+	_ storage.UploadManager = (*Client)(nil)
+)
+
+// Put uploads an object to S3 with the headers and metadata in opts. Bodies smaller than
+// the multipart threshold are sent with a single PutObject, larger ones and bodies of unknown
+// size are uploaded in parts by the SDK upload manager. With IfNotExists, bodies of unknown
+// size are buffered up to the threshold first so small ones are still written conditionally.
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, opts storage.PutOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket:   aws.String(c.config.Bucket),
+		Key:      aws.String(key),
+		Body:     body,
+		Metadata: opts.Metadata,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+
+	size, ok := bodySize(body)
+	if !ok && opts.IfNotExists {
+		head, err := io.ReadAll(io.LimitReader(body, c.config.MultipartThreshold))
+		if err != nil {
+			return fmt.Errorf("error reading upload body: %w", err)
+		}
+		if int64(len(head)) < c.config.MultipartThreshold {
+			input.Body = bytes.NewReader(head)
+			return c.putObject(ctx, input, opts.IfNotExists)
+		}
+		input.Body = io.MultiReader(bytes.NewReader(head), body)
+	}
+
+	if ok && size < c.config.MultipartThreshold {
+		return c.putObject(ctx, input, opts.IfNotExists)
+	}
+	return c.uploadMultipart(ctx, input, opts.IfNotExists)
+}
+
+// putObject uploads an object with a single PutObject request
+func (c *Client) putObject(ctx context.Context, input *s3.PutObjectInput, ifNotExists bool) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var optFns []func(*s3.Options)
+	if ifNotExists {
+		if !c.config.ConditionalWrites {
+			// Without conditional writes there is a window between the check and the write
+			if err := c.checkNotExists(ctx, aws.ToString(input.Key)); err != nil {
+				return err
+			}
+		} else {
+			// The SDK version in use has no IfNoneMatch field, so the header is added directly
+			optFns = append(optFns, s3.WithAPIOptions(smithyhttp.AddHeaderValue("If-None-Match", "*")))
+		}
+	}
+
+	_, err := c.s3Client.PutObject(ctx, input, optFns...)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return storage.ErrExists
+		}
+		return fmt.Errorf("error uploading to S3: %w", classifyError(err))
+	}
+
+	return nil
+}
+
+// uploadMultipart uploads an object in parts of part_size, part_concurrency at a time.
+// Parts of a failed or cancelled upload are aborted so they do not accrue storage costs.
+// The request timeout applies to each part rather than to the whole upload.
+func (c *Client) uploadMultipart(ctx context.Context, input *s3.PutObjectInput, ifNotExists bool) error {
+	conditional := ifNotExists && c.config.ConditionalWrites
+	if ifNotExists && !conditional {
+		// Without conditional writes there is a window between the check and the write
+		if err := c.checkNotExists(ctx, aws.ToString(input.Key)); err != nil {
+			return err
+		}
+	}
+
+	uploader := manager.NewUploader(c.s3Client, func(u *manager.Uploader) {
+		u.PartSize = c.config.PartSize
+		u.Concurrency = c.config.PartConcurrency
+		// Parts are aborted below with a context that survives cancellation
+		u.LeavePartsOnError = true
+		if c.config.Timeout > 0 {
+			u.ClientOptions = append(u.ClientOptions, func(o *s3.Options) {
+				o.APIOptions = append(o.APIOptions, c.requestTimeout)
+			})
+		}
+		if conditional {
+			u.ClientOptions = append(u.ClientOptions, func(o *s3.Options) {
+				o.APIOptions = append(o.APIOptions, ifNoneMatchOnWrite)
+			})
+		}
+	})
+
+	_, err := uploader.Upload(ctx, input)
+	if err != nil {
+		var failure manager.MultiUploadFailure
+		if errors.As(err, &failure) {
+			c.abortUpload(ctx, aws.ToString(input.Key), failure.UploadID())
+		}
+		if conditional && isPreconditionFailed(err) {
+			return storage.ErrExists
+		}
+		return fmt.Errorf("error uploading to S3: %w", classifyError(err))
+	}
+
+	return nil
+}
+
+// ifNoneMatchOnWrite adds If-None-Match: * to the requests of the upload manager that
+// create the object: CompleteMultipartUpload, or PutObject for bodies that fit in one part
+func ifNoneMatchOnWrite(stack *middleware.Stack) error {
+	switch stack.ID() {
+	case "CompleteMultipartUpload", "PutObject":
+		return smithyhttp.AddHeaderValue("If-None-Match", "*")(stack)
+	default:
+		return nil
+	}
+}
+
+// checkNotExists returns storage.ErrExists if an object exists under key
+func (c *Client) checkNotExists(ctx context.Context, key string) error {
+	exists, err := storage.Exists(ctx, c, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return storage.ErrExists
+	}
+	return nil
+}
+
+// abortUpload aborts a multipart upload, using a fresh context so the upload is
+// aborted even after cancellation
+func (c *Client) abortUpload(ctx context.Context, key, uploadID string) error {
+	ctx, cancel := c.withTimeout(context.WithoutCancel(ctx))
+	defer cancel()
+
+	_, err := c.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("error aborting upload of %s: %w", key, classifyError(err))
+	}
+	return nil
+}
+
+// ListUploads calls fn for each incomplete multipart upload whose key starts with prefix
+func (c *Client) ListUploads(ctx context.Context, prefix string, fn func(storage.Upload) error) error {
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(c.config.Bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	for {
+		page, err := c.listUploadsPage(ctx, input)
+		if err != nil {
+			return fmt.Errorf("error listing uploads in S3: %w", classifyError(err))
+		}
+
+		for _, upload := range page.Uploads {
+			err := fn(storage.Upload{
+				Key:       aws.ToString(upload.Key),
+				ID:        aws.ToString(upload.UploadId),
+				Initiated: aws.ToTime(upload.Initiated),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			return nil
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.UploadIdMarker = page.NextUploadIdMarker
+	}
+}
+
+// listUploadsPage fetches one page of incomplete uploads within the request timeout
+func (c *Client) listUploadsPage(ctx context.Context, input *s3.ListMultipartUploadsInput) (*s3.ListMultipartUploadsOutput, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.s3Client.ListMultipartUploads(ctx, input)
+}
+
+// AbortUpload discards an incomplete multipart upload and its parts
+func (c *Client) AbortUpload(ctx context.Context, upload storage.Upload) error {
+	return c.abortUpload(ctx, upload.Key, upload.ID)
+}
+
+// Get opens an object in S3 for reading. The request timeout also covers reading
+// the body, so the caller must close it.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, cancel := c.withTimeout(ctx)
+
+	result, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(c.config.Bucket),
 		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
 	})
 
 	if err != nil {
-		return fmt.Errorf("error uploading to S3: %w", err)
+		cancel()
+		if isNotFound(err) {
+			return nil, fmt.Errorf("error getting object %s: %w", key, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error getting object from S3: %w", classifyError(err))
+	}
+
+	return &cancelOnClose{ReadCloser: result.Body, cancel: cancel}, nil
+}
+
+// cancelOnClose releases the request context of a body once it is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels its request context
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// bodySize returns the number of bytes left in a body if it can be determined without reading it
+func bodySize(body io.Reader) (int64, bool) {
+	switch b := body.(type) {
+	case interface{ Len() int }:
+		return int64(b.Len()), true
+	case io.Seeker:
+		current, err := b.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := b.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := b.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - current, true
+	default:
+		return 0, false
+	}
+}
+
+// Head returns information about an object in S3
+func (c *Client) Head(ctx context.Context, key string) (storage.Object, error) {
+	result, err := c.headObject(ctx, key)
+	if err != nil {
+		if isNotFound(err) {
+			return storage.Object{}, storage.ErrNotFound
+		}
+		return storage.Object{}, fmt.Errorf("error checking if object exists: %w", classifyError(err))
+	}
+
+	return storage.Object{
+		Key:          key,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		URL:          c.URL(key),
+	}, nil
+}
+
+// Validate checks with a HeadBucket request that the bucket exists and the credentials can access it
+func (c *Client) Validate(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(c.config.Bucket),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return errs.Wrap(errs.ErrConfig, fmt.Errorf("bucket %s does not exist", c.config.Bucket))
+		}
+		return fmt.Errorf("error accessing bucket %s: %w", c.config.Bucket, classifyError(err))
+	}
+	return nil
+}
+
+// Copy copies an object server-side, using a multipart copy for objects over 5 GiB
+func (c *Client) Copy(ctx context.Context, sourceKey, targetKey string) error {
+	head, err := c.headObject(ctx, sourceKey)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("error copying object %s: %w", sourceKey, storage.ErrNotFound)
+		}
+		return fmt.Errorf("error copying object in S3: %w", classifyError(err))
+	}
+
+	if aws.ToInt64(head.ContentLength) <= maxCopyObjectSize {
+		err = c.copyObject(ctx, sourceKey, targetKey)
+	} else {
+		err = c.multipartCopy(ctx, sourceKey, targetKey, head)
+	}
+	if err != nil {
+		return fmt.Errorf("error copying object in S3: %w", classifyError(err))
 	}
 
 	return nil
 }
 
-// GetObject downloads an object from S3
-func (c *Client) GetObject(key string) ([]byte, error) {
-	ctx := context.Background()
-	result, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
+// headObject issues a HeadObject request
+func (c *Client) headObject(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(c.config.Bucket),
 		Key:    aws.String(key),
 	})
+}
+
+// copyObject copies an object with a single CopyObject request
+func (c *Client) copyObject(ctx context.Context, sourceKey, targetKey string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(c.config.Bucket),
+		Key:        aws.String(targetKey),
+		CopySource: aws.String(c.copySource(sourceKey)),
+	})
+	return err
+}
+
+// multipartCopy copies a large object part by part with UploadPartCopy,
+// aborting the multipart upload if any part fails
+func (c *Client) multipartCopy(ctx context.Context, sourceKey, targetKey string, head *s3.HeadObjectOutput) error {
+	upload, err := c.createCopyUpload(ctx, targetKey, head)
+	if err != nil {
+		return err
+	}
+
+	parts, err := c.copyParts(ctx, sourceKey, targetKey, upload.UploadId, aws.ToInt64(head.ContentLength))
+	if err == nil {
+		err = c.completeCopyUpload(ctx, targetKey, upload.UploadId, parts)
+	}
+	if err != nil {
+		_ = c.abortUpload(ctx, targetKey, aws.ToString(upload.UploadId))
+		return err
+	}
+
+	return nil
+}
+
+// createCopyUpload starts the multipart upload of a copy, keeping the headers of the source
+func (c *Client) createCopyUpload(ctx context.Context, targetKey string, head *s3.HeadObjectOutput) (*s3.CreateMultipartUploadOutput, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(c.config.Bucket),
+		Key:          aws.String(targetKey),
+		ContentType:  head.ContentType,
+		CacheControl: head.CacheControl,
+		Metadata:     head.Metadata,
+	})
+}
+
+// completeCopyUpload assembles the copied parts into the target object
+func (c *Client) completeCopyUpload(ctx context.Context, targetKey string, uploadID *string, parts []types.CompletedPart) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.config.Bucket),
+		Key:             aws.String(targetKey),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+// copyParts copies size bytes of sourceKey into the parts of a multipart upload
+func (c *Client) copyParts(ctx context.Context, sourceKey, targetKey string, uploadID *string, size int64) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	for start, number := int64(0), int32(1); start < size; start, number = start+copyPartSize, number+1 {
+		end := min(start+copyPartSize, size) - 1
+
+		part, err := c.copyPart(ctx, sourceKey, targetKey, uploadID, number, start, end)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// copyPart copies the byte range start-end of sourceKey as one part of a multipart upload
+func (c *Client) copyPart(ctx context.Context, sourceKey, targetKey string, uploadID *string, number int32, start, end int64) (types.CompletedPart, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	result, err := c.s3Client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+		Bucket:          aws.String(c.config.Bucket),
+		Key:             aws.String(targetKey),
+		UploadId:        uploadID,
+		PartNumber:      aws.Int32(number),
+		CopySource:      aws.String(c.copySource(sourceKey)),
+		CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	})
+	if err != nil {
+		return types.CompletedPart{}, fmt.Errorf("error copying part %d: %w", number, classifyError(err))
+	}
 
+	return types.CompletedPart{
+		ETag:       result.CopyPartResult.ETag,
+		PartNumber: aws.Int32(number),
+	}, nil
+}
+
+// copySource returns the URL-encoded bucket/key value for CopySource parameters
+func (c *Client) copySource(key string) string {
+	return c.config.Bucket + "/" + storage.EscapeKey(key)
+}
+
+// PresignGet returns a presigned URL to download an object
+func (c *Client) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if err := CheckPresignExpiry(expires); err != nil {
+		return "", err
+	}
+
+	request, err := s3.NewPresignClient(c.s3Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.config.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
 	if err != nil {
-		return nil, fmt.Errorf("error getting object from S3: %w", err)
+		return "", fmt.Errorf("error presigning download of %s: %w", key, classifyError(err))
 	}
+	return request.URL, nil
+}
 
-	defer result.Body.Close()
+// PresignPut returns a presigned URL to upload an object. When contentType is set the
+// upload must send the same Content-Type header.
+func (c *Client) PresignPut(ctx context.Context, key string, expires time.Duration, contentType string) (string, error) {
+	if err := CheckPresignExpiry(expires); err != nil {
+		return "", err
+	}
 
-	// Read the object body
-	data := bytes.Buffer{}
-	_, err = data.ReadFrom(result.Body)
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(c.config.Bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	request, err := s3.NewPresignClient(c.s3Client).PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
 	if err != nil {
-		return nil, fmt.Errorf("error reading object body: %w", err)
+		return "", fmt.Errorf("error presigning upload of %s: %w", key, classifyError(err))
 	}
+	return request.URL, nil
+}
 
-	return data.Bytes(), nil
+// CheckPresignExpiry rejects validity periods that S3 does not accept
+func CheckPresignExpiry(expires time.Duration) error {
+	if expires <= 0 || expires > maxPresignExpiry {
+		return fmt.Errorf("presigned URL expiry must be between 1s and %s, got %s", maxPresignExpiry, expires)
+	}
+	return nil
 }
 
-// ObjectExists checks if an object exists in S3
-func (c *Client) ObjectExists(key string) (bool, error) {
-	ctx := context.Background()
-	_, err := c.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+// Delete removes an object from S3
+func (c *Client) Delete(ctx context.Context, key string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(c.config.Bucket),
 		Key:    aws.String(key),
 	})
 
 	if err != nil {
-		// Check if the error is because the object doesn't exist
-		if strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "NoSuchKey") {
-			return false, nil
+		return fmt.Errorf("error deleting object from S3: %w", classifyError(err))
+	}
+
+	return nil
+}
+
+// DeleteBatch removes objects from S3 using DeleteObjects requests of up to 1000 keys.
+// When a request fails, its keys and those of later batches are returned with the error.
+func (c *Client) DeleteBatch(ctx context.Context, keys []string) ([]storage.DeleteError, error) {
+	var failed []storage.DeleteError
+	for start := 0; start < len(keys); start += maxDeleteBatchSize {
+		end := min(start+maxDeleteBatchSize, len(keys))
+
+		failures, err := c.deleteObjects(ctx, keys[start:end])
+		if err != nil {
+			err = fmt.Errorf("error deleting objects from S3: %w", classifyError(err))
+			for _, key := range keys[start:] {
+				failed = append(failed, storage.DeleteError{Key: key, Err: err})
+			}
+			return failed, err
 		}
-		return false, fmt.Errorf("error checking if object exists: %w", err)
+		failed = append(failed, failures...)
 	}
 
-	return true, nil
+	return failed, nil
 }
 
-// GetFileURL returns the URL for an uploaded file
-func (c *Client) GetFileURL(key string) string {
-	if c.config.Endpoint != "" {
-		// For custom S3 endpoints
-		// Format: https://imgood.s3.example.com/path/to/file.jpeg
-		return fmt.Sprintf("https://%s.%s/%s", c.config.Bucket, strings.TrimPrefix(c.config.Endpoint, "https://"), key)
+// deleteObjects issues a single DeleteObjects request and collects per-key failures
+func (c *Client) deleteObjects(ctx context.Context, keys []string) ([]storage.DeleteError, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
 	}
-	// For AWS S3
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", c.config.Bucket, c.config.Region, key)
+
+	result, err := c.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(c.config.Bucket),
+		Delete: &types.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	failed := make([]storage.DeleteError, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		failed = append(failed, storage.DeleteError{
+			Key: aws.ToString(e.Key),
+			Err: classifyCode(aws.ToString(e.Code), fmt.Errorf("%s: %s", aws.ToString(e.Code), aws.ToString(e.Message))),
+		})
+	}
+
+	return failed, nil
 }
 
-// S3Object represents an object in S3
-type S3Object struct {
-	Key          string
-	Size         int64
-	LastModified time.Time
-	URL          string
+// URL returns the URL for an uploaded file
+func (c *Client) URL(key string) string {
+	escaped := storage.EscapeKey(key)
+
+	// For a CDN or custom domain, e.g. https://img.example.com/{key}
+	if c.config.PublicURL != "" {
+		return publicURL(c.config.PublicURL, c.config.Bucket, escaped)
+	}
+
+	// For AWS S3
+	// Format: https://imgood.s3.us-east-1.amazonaws.com/path/to/file.jpeg
+	endpoint := &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", c.config.Region)}
+
+	// For custom S3 endpoints, keeping their scheme and path
+	// Format: https://imgood.s3.example.com/path/to/file.jpeg
+	if c.config.Endpoint != "" {
+		parsed, err := url.Parse(c.config.Endpoint)
+		if err != nil || parsed.Host == "" {
+			// Endpoints without a scheme, e.g. "s3.example.com"
+			parsed = &url.URL{Scheme: "https", Host: strings.TrimSuffix(c.config.Endpoint, "/")}
+		}
+		endpoint = parsed
+	}
+
+	base := strings.TrimSuffix(endpoint.Path, "/")
+	if c.config.PathStyle {
+		// Format: https://s3.example.com/imgood/path/to/file.jpeg
+		return fmt.Sprintf("%s://%s%s/%s/%s", endpoint.Scheme, endpoint.Host, base, url.PathEscape(c.config.Bucket), escaped)
+	}
+	return fmt.Sprintf("%s://%s.%s%s/%s", endpoint.Scheme, c.config.Bucket, endpoint.Host, base, escaped)
 }
 
-// ListObjects lists objects in the S3 bucket with an optional prefix
-func (c *Client) ListObjects(prefix string, maxKeys int32) ([]S3Object, error) {
-	ctx := context.Background()
+// publicURL expands a public URL template with {key} and {bucket}. A template
+// without {key} is used as a base URL that the key is appended to.
+func publicURL(template, bucket, escapedKey string) string {
+	if !strings.Contains(template, "{key}") {
+		template = strings.TrimSuffix(template, "/") + "/{key}"
+	}
+	return strings.NewReplacer("{key}", escapedKey, "{bucket}", bucket).Replace(template)
+}
 
+// List calls fn for each object in the S3 bucket with an optional prefix,
+// following continuation tokens until limit objects were seen (0 for no limit)
+func (c *Client) List(ctx context.Context, prefix string, limit int, fn func(storage.Object) error) error {
 	// Create the input for listing objects
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(c.config.Bucket),
@@ -139,33 +685,137 @@ func (c *Client) ListObjects(prefix string, maxKeys int32) ([]S3Object, error) {
 		input.Prefix = aws.String(prefix)
 	}
 
-	// Set max keys if provided
-	if maxKeys > 0 {
-		input.MaxKeys = aws.Int32(maxKeys)
+	// Don't fetch more keys per page than needed
+	if limit > 0 && limit < maxListPageSize {
+		input.MaxKeys = aws.Int32(int32(limit))
 	}
 
-	// List objects
-	result, err := c.s3Client.ListObjectsV2(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("error listing objects in S3: %w", err)
+	count := 0
+	paginator := s3.NewListObjectsV2Paginator(c.s3Client, input)
+	for paginator.HasMorePages() {
+		page, err := c.listPage(ctx, paginator)
+		if err != nil {
+			return fmt.Errorf("error listing objects in S3: %w", classifyError(err))
+		}
+
+		for _, item := range page.Contents {
+			err := fn(storage.Object{
+				Key:          aws.ToString(item.Key),
+				Size:         aws.ToInt64(item.Size),
+				LastModified: aws.ToTime(item.LastModified),
+				ETag:         strings.Trim(aws.ToString(item.ETag), `"`),
+				URL:          c.URL(aws.ToString(item.Key)),
+			})
+			if err != nil {
+				return err
+			}
+
+			count++
+			if limit > 0 && count >= limit {
+				return nil
+			}
+		}
 	}
 
-	// Convert to S3Object slice
-	objects := make([]S3Object, 0, len(result.Contents))
-	for _, item := range result.Contents {
-		objects = append(objects, S3Object{
-			Key:          *item.Key,
-			Size:         *item.Size,
-			LastModified: *item.LastModified,
-			URL:          c.GetFileURL(*item.Key),
-		})
+	return nil
+}
+
+// listPage fetches the next page of a listing, applying the per-request timeout
+func (c *Client) listPage(ctx context.Context, paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return paginator.NextPage(ctx)
+}
+
+// withTimeout applies the configured per-request timeout to ctx
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.Timeout > 0 {
+		return context.WithTimeout(ctx, c.config.Timeout)
 	}
+	return context.WithCancel(ctx)
+}
+
+// requestTimeout limits each request of a multipart upload to the configured timeout
+func (c *Client) requestTimeout(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("imgoodRequestTimeout",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			ctx, cancel := c.withTimeout(ctx)
+			defer cancel()
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+}
+
+// isNotFound checks if an S3 error means the object doesn't exist
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "NoSuchKey")
+}
+
+// isPreconditionFailed reports whether a conditional write failed because the object exists
+func isPreconditionFailed(err error) bool {
+	return strings.Contains(err.Error(), "PreconditionFailed") || strings.Contains(err.Error(), "ConditionalRequestConflict")
+}
+
+// authErrorCodes are the S3 error codes of missing, invalid or insufficient credentials
+var authErrorCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AllAccessDisabled":     true,
+	"ExpiredToken":          true,
+	"Forbidden":             true,
+	"InvalidAccessKeyId":    true,
+	"InvalidToken":          true,
+	"SignatureDoesNotMatch": true,
+}
 
-	return objects, nil
+// classifyError tags an S3 error with its category so the command can exit with the
+// matching code: authentication failures, a missing bucket and unreachable endpoints
+func classifyError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if tagged := classifyCode(apiErr.ErrorCode(), err); tagged != err {
+			return tagged
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return errs.Wrap(errs.ErrAuth, err)
+		}
+		return err
+	}
+
+	// Credentials are resolved before the request is sent
+	if strings.Contains(err.Error(), "failed to retrieve credentials") {
+		return errs.Wrap(errs.ErrAuth, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return errs.Wrap(errs.ErrNetwork, err)
+	}
+	return err
+}
+
+// classifyCode tags err with the category of an S3 error code
+func classifyCode(code string, err error) error {
+	switch {
+	case authErrorCodes[code]:
+		return errs.Wrap(errs.ErrAuth, err)
+	case code == "NoSuchBucket":
+		return errs.Wrap(errs.ErrConfig, err)
+	default:
+		return err
+	}
 }
 
 // configureAWS sets up the AWS configuration with the provided credentials and region
-func configureAWS(region, accessKey, secretKey string) (aws.Config, error) {
+func configureAWS(ctx context.Context, region, accessKey, secretKey string) (aws.Config, error) {
 	configOptions := []func(*awsconfig.LoadOptions) error{
 		awsconfig.WithRegion(region),
 	}
@@ -178,7 +828,7 @@ func configureAWS(region, accessKey, secretKey string) (aws.Config, error) {
 	}
 
 	// Load the AWS configuration
-	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), configOptions...)
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, configOptions...)
 	if err != nil {
 		return cfg, err
 	}
@@ -0,0 +1,170 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mingeme/imgood/internal/config"
+	"github.com/mingeme/imgood/internal/storage"
+)
+
+func TestNewClientValidatesMultipart(t *testing.T) {
+	valid := config.S3Config{Bucket: "images", Region: "us-east-1", MultipartThreshold: 64 << 20, PartSize: 16 << 20}
+
+	tests := []struct {
+		name    string
+		modify  func(*config.S3Config)
+		wantErr string
+	}{
+		{name: "no bucket", modify: func(c *config.S3Config) { c.Bucket = "" }, wantErr: "bucket name is required"},
+		{name: "zero threshold", modify: func(c *config.S3Config) { c.MultipartThreshold = 0 }, wantErr: "multipart_threshold"},
+		{name: "negative threshold", modify: func(c *config.S3Config) { c.MultipartThreshold = -1 }, wantErr: "multipart_threshold"},
+		{name: "small parts", modify: func(c *config.S3Config) { c.PartSize = 1 << 20 }, wantErr: "part_size must be at least 5MB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			_, err := NewClient(context.Background(), cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewClient() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeS3 records the requests of an upload and answers them like S3
+type fakeS3 struct {
+	mu       sync.Mutex
+	requests []string
+	exists   bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	query := r.URL.Query()
+
+	var op string
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		op = "CreateMultipartUpload"
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		op = "UploadPart"
+		w.Header().Set("ETag", `"part"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		op = "CompleteMultipartUpload"
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		op = "AbortMultipartUpload"
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		op = "PutObject"
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		op += " If-None-Match: " + match
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, op)
+	f.mu.Unlock()
+
+	if strings.HasSuffix(op, "If-None-Match: *") && f.exists {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+		return
+	}
+	if op == "CompleteMultipartUpload If-None-Match: *" || op == "CompleteMultipartUpload" {
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Key>photo.jpg</Key></CompleteMultipartUploadResult>`)
+	}
+}
+
+func TestPutIfNotExists(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         io.Reader
+		exists       bool
+		wantErr      error
+		wantRequests []string
+	}{
+		{
+			name:         "unknown size below the threshold",
+			body:         io.LimitReader(strings.NewReader("small"), 1<<10),
+			wantRequests: []string{"PutObject If-None-Match: *"},
+		},
+		{
+			name:         "unknown size below the threshold exists",
+			body:         io.LimitReader(strings.NewReader("small"), 1<<10),
+			exists:       true,
+			wantErr:      storage.ErrExists,
+			wantRequests: []string{"PutObject If-None-Match: *"},
+		},
+		{
+			name:         "unknown size from the threshold in one part",
+			body:         io.LimitReader(strings.NewReader(strings.Repeat("x", 100)), 1<<10),
+			wantRequests: []string{"PutObject If-None-Match: *"},
+		},
+		{
+			name: "unknown size from the threshold in parts",
+			body: io.LimitReader(strings.NewReader(strings.Repeat("x", 6<<20)), 6<<20),
+			wantRequests: []string{
+				"CreateMultipartUpload",
+				"UploadPart",
+				"UploadPart",
+				"CompleteMultipartUpload If-None-Match: *",
+			},
+		},
+		{
+			name:    "known size from the threshold exists",
+			body:    strings.NewReader(strings.Repeat("x", 6<<20)),
+			exists:  true,
+			wantErr: storage.ErrExists,
+			wantRequests: []string{
+				"CreateMultipartUpload",
+				"UploadPart",
+				"UploadPart",
+				"CompleteMultipartUpload If-None-Match: *",
+				"AbortMultipartUpload",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{exists: tt.exists}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client, err := NewClient(context.Background(), config.S3Config{
+				Bucket:             "images",
+				Endpoint:           server.URL,
+				Region:             "us-east-1",
+				AccessKey:          "key",
+				SecretKey:          "secret",
+				PathStyle:          true,
+				ConditionalWrites:  true,
+				MultipartThreshold: 64,
+				PartSize:           5 << 20,
+				PartConcurrency:    1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Put(context.Background(), "photo.jpg", tt.body, storage.PutOptions{IfNotExists: true})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Put() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := strings.Join(fake.requests, ", "); got != strings.Join(tt.wantRequests, ", ") {
+				t.Errorf("requests = %s, want %s", got, strings.Join(tt.wantRequests, ", "))
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mingeme/imgood/internal/config"
+)
+
+// Local stores objects as files below a root directory
+type Local struct {
+	root   string
+	config config.LocalConfig
+}
+
+// NewLocal creates a local filesystem storage with the provided configuration
+func NewLocal(cfg config.LocalConfig) (*Local, error) {
+	if cfg.Root == "" {
+		return nil, fmt.Errorf("local storage root directory is required")
+	}
+
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving local storage root: %w", err)
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating local storage root: %w", err)
+	}
+
+	return &Local{
+		root:   root,
+		config: cfg,
+	}, nil
+}
+
+var (
+	_ Storage   = (*Local)(nil)
+	_ Copier    = (*Local)(nil)
+	_ Validator = (*Local)(nil)
+)
+
+// Put writes body to the file for key, creating parent directories as needed.
+// Files have no headers, so only opts.IfNotExists is used.
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	return writeFile(path, body, opts.IfNotExists)
+}
+
+// Copy copies the file for sourceKey to the file for targetKey
+func (l *Local) Copy(ctx context.Context, sourceKey, targetKey string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sourcePath, err := l.Path(sourceKey)
+	if err != nil {
+		return err
+	}
+	targetPath, err := l.Path(targetKey)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error copying object %s: %w", sourceKey, ErrNotFound)
+		}
+		return fmt.Errorf("error copying object: %w", err)
+	}
+	defer source.Close()
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	return writeFile(targetPath, source, false)
+}
+
+// Get opens the file for key
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("error reading object %s: %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("error reading object: %w", err)
+	}
+
+	return file, nil
+}
+
+// Head returns information about the file for key
+func (l *Local) Head(ctx context.Context, key string) (Object, error) {
+	if err := ctx.Err(); err != nil {
+		return Object{}, err
+	}
+
+	path, err := l.Path(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Object{}, ErrNotFound
+		}
+		return Object{}, fmt.Errorf("error checking if object exists: %w", err)
+	}
+	if info.IsDir() {
+		return Object{}, ErrNotFound
+	}
+
+	return l.object(key, info), nil
+}
+
+// List walks the root directory and calls fn for each file whose key starts with prefix
+func (l *Local) List(ctx context.Context, prefix string, limit int, fn func(Object) error) error {
+	count := 0
+	var fnErr error
+	err := filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			// Skip directories that cannot contain matching keys
+			if key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".imgood-") || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(l.object(key, info)); err != nil {
+			fnErr = err
+			return fs.SkipAll
+		}
+
+		count++
+		if limit > 0 && count >= limit {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error listing objects: %w", err)
+	}
+
+	return fnErr
+}
+
+// Delete removes the file for key and any parent directories left empty
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error deleting object %s: %w", key, ErrNotFound)
+		}
+		return fmt.Errorf("error deleting object: %w", err)
+	}
+
+	// Remove directories left empty, like prefixes disappear in S3
+	for dir := filepath.Dir(path); dir != l.root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the root directory exists and is a directory
+func (l *Local) Validate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(l.root)
+	if err != nil {
+		return fmt.Errorf("error accessing root directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("root is not a directory: %s", l.root)
+	}
+	return nil
+}
+
+// URL returns the public URL for key, using base_url when configured
+func (l *Local) URL(key string) string {
+	if l.config.BaseURL != "" {
+		return strings.TrimSuffix(l.config.BaseURL, "/") + "/" + EscapeKey(key)
+	}
+
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(l.root, filepath.FromSlash(key)))}
+	return u.String()
+}
+
+// Path maps a key to a file path, rejecting keys that escape the root directory
+func (l *Local) Path(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("object key is required")
+	}
+
+	// A root such as "/" already ends in a separator
+	prefix := l.root
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+
+	path := filepath.Join(l.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, prefix) || path == prefix {
+		return "", fmt.Errorf("invalid object key: %s", key)
+	}
+
+	return path, nil
+}
+
+// writeFile writes r to path through a temporary file so readers never see a partial object.
+// With exclusive, the file is linked into place so an existing file is never replaced.
+func writeFile(path string, r io.Reader, exclusive bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".imgood-*")
+	if err != nil {
+		return fmt.Errorf("error writing object: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing object: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("error writing object: %w", err)
+	}
+
+	if exclusive {
+		// Unlike rename, link fails atomically if the target exists
+		if err := os.Link(tmp.Name(), path); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				return ErrExists
+			}
+			return fmt.Errorf("error writing object: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing object: %w", err)
+	}
+
+	return nil
+}
+
+// object builds an Object from file information
+func (l *Local) object(key string, info fs.FileInfo) Object {
+	return Object{
+		Key:          key,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		URL:          l.URL(key),
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mingeme/imgood/internal/errs"
+)
+
+var (
+	// ErrNotFound is returned when an object does not exist in the storage backend
+	ErrNotFound = errs.Wrap(errs.ErrNotFound, errors.New("object not found"))
+	// ErrExists is returned by Put with IfNotExists when an object already exists under the key
+	ErrExists = errs.Wrap(errs.ErrConflict, errors.New("object already exists"))
+)
+
+// Object represents a stored object
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string // without quotes, empty if the backend has none
+	URL          string
+}
+
+// PutOptions holds the HTTP headers and user metadata stored with an object, and
+// write conditions. Backends without object headers, like the local filesystem, ignore
+// the headers and metadata.
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+	// Metadata is stored as x-amz-meta-* headers on S3
+	Metadata map[string]string
+	// IfNotExists makes Put fail with ErrExists instead of replacing an existing object.
+	// Backends check this atomically where they can.
+	IfNotExists bool
+}
+
+// Storage is the interface implemented by every storage backend
+type Storage interface {
+	// Put stores the content read from body under the given key with opts, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error
+	// Get opens the object stored under key for reading, the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Head returns information about an object, or ErrNotFound if it does not exist
+	Head(ctx context.Context, key string) (Object, error)
+	// List calls fn for each object whose key starts with prefix, in key order,
+	// stopping after limit objects (0 for no limit) or when fn returns an error
+	List(ctx context.Context, prefix string, limit int, fn func(Object) error) error
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL for a key
+	URL(key string) string
+}
+
+// Copier is implemented by backends that can copy objects without transferring their content through the client
+type Copier interface {
+	// Copy duplicates the object stored under sourceKey to targetKey
+	Copy(ctx context.Context, sourceKey, targetKey string) error
+}
+
+// BatchDeleter is implemented by backends that can delete several objects in one request
+type BatchDeleter interface {
+	// DeleteBatch removes the objects stored under keys and returns the keys that could not
+	// be deleted, including the keys not attempted when it stops with an error
+	DeleteBatch(ctx context.Context, keys []string) ([]DeleteError, error)
+}
+
+// Validator is implemented by backends that can check their configuration and credentials
+type Validator interface {
+	// Validate checks that the backend is reachable and accessible without changing anything
+	Validate(ctx context.Context) error
+}
+
+// Presigner is implemented by backends that can create time-limited URLs which
+// grant access to a single object without credentials
+type Presigner interface {
+	// PresignGet returns a URL to download the object stored under key
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+	// PresignPut returns a URL to upload an object under key, restricted to contentType if not empty
+	PresignPut(ctx context.Context, key string, expires time.Duration, contentType string) (string, error)
+}
+
+// UploadManager is implemented by backends that keep the parts of incomplete uploads
+type UploadManager interface {
+	// ListUploads calls fn for each incomplete upload whose key starts with prefix
+	ListUploads(ctx context.Context, prefix string, fn func(Upload) error) error
+	// AbortUpload discards an incomplete upload and its parts
+	AbortUpload(ctx context.Context, upload Upload) error
+}
+
+// Upload is an incomplete multipart upload
+type Upload struct {
+	Key       string
+	ID        string
+	Initiated time.Time
+}
+
+// DeleteError describes an object that could not be deleted
+type DeleteError struct {
+	Key string
+	Err error
+}
+
+// Exists reports whether an object exists under the given key
+func Exists(ctx context.Context, s Storage, key string) (bool, error) {
+	_, err := s.Head(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ListAll collects the objects whose keys start with prefix, up to limit (0 for no limit)
+func ListAll(ctx context.Context, s Storage, prefix string, limit int) ([]Object, error) {
+	objects := make([]Object, 0)
+	err := s.List(ctx, prefix, limit, func(obj Object) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// DeleteAll removes the objects stored under keys, using batched requests when the
+// backend supports them, and returns the keys that could not be deleted. When it stops
+// with an error, the keys that were not attempted are also returned with that error,
+// so every key that is not returned was deleted.
+func DeleteAll(ctx context.Context, s Storage, keys []string) ([]DeleteError, error) {
+	if bd, ok := s.(BatchDeleter); ok {
+		return bd.DeleteBatch(ctx, keys)
+	}
+
+	var failed []DeleteError
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			for _, rest := range keys[i:] {
+				failed = append(failed, DeleteError{Key: rest, Err: err})
+			}
+			return failed, err
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			failed = append(failed, DeleteError{Key: key, Err: err})
+		}
+	}
+	return failed, nil
+}
+
+// Copy duplicates an object, server-side when the backend supports it and by
+// downloading and uploading the content otherwise
+func Copy(ctx context.Context, s Storage, sourceKey, targetKey string) error {
+	if c, ok := s.(Copier); ok {
+		return c.Copy(ctx, sourceKey, targetKey)
+	}
+
+	body, err := s.Get(ctx, sourceKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return s.Put(ctx, targetKey, body, PutOptions{})
+}
+
+// GetBytes reads the whole content of the object stored under key into memory
+func GetBytes(ctx context.Context, s Storage, key string) ([]byte, error) {
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading object %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// EscapeKey URL-escapes each segment of a key, keeping the "/" separators, so keys
+// with spaces or unicode characters can be used in URL paths
+func EscapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}